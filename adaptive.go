@@ -0,0 +1,105 @@
+package tusc
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultMinChunkSizeBytes = 256 * 1024
+	defaultMaxChunkSizeBytes = 128 * 1024 * 1024
+	// adaptiveTargetDuration is how long a chunk should take, long enough to amortise the round trip but short
+	//   enough that a failure doesn't lose much work
+	adaptiveTargetDuration = 2 * time.Second
+	// adaptiveStableChunks consecutive successes before a chunk is grown
+	adaptiveStableChunks = 2
+)
+
+// adaptiveChunker sizes chunks from measured throughput, bounded by Config.MinChunkSizeBytes and
+// Config.MaxChunkSizeBytes. Chunks only grow after a run of successes but shrink on the first failure.
+type adaptiveChunker struct {
+	size      int64
+	min       int64
+	max       int64
+	successes int
+}
+
+func newAdaptiveChunker(_config *Config) *adaptiveChunker {
+	return &adaptiveChunker{
+		size: min(max(_config.ChunkSizeBytes, _config.MinChunkSizeBytes), _config.MaxChunkSizeBytes),
+		min:  _config.MinChunkSizeBytes,
+		max:  _config.MaxChunkSizeBytes,
+	}
+}
+
+func (a *adaptiveChunker) success(_bytes int64, _elapsed time.Duration) {
+	a.successes++
+	if _elapsed <= 0 {
+		_elapsed = time.Millisecond
+	}
+
+	// bytes that would be sent in adaptiveTargetDuration at the measured rate
+	ideal := int64(float64(_bytes) / _elapsed.Seconds() * adaptiveTargetDuration.Seconds())
+
+	switch {
+	case ideal < a.size/2:
+		// link is slower than the chunk size suits, no need to wait for a failure
+		a.resize(a.size / 2)
+		a.successes = 0
+	case ideal > a.size && a.successes >= adaptiveStableChunks:
+		a.resize(min(a.size*2, ideal))
+		a.successes = 0
+	}
+}
+
+func (a *adaptiveChunker) failure(_err error) {
+	a.successes = 0
+	if errors.Is(_err, ErrLargeUpload) {
+		// server rejected the request size, never grow back beyond it
+		a.max = max(a.min, a.size/2)
+	}
+	a.resize(a.size / 2)
+}
+
+func (a *adaptiveChunker) resize(_size int64) {
+	a.size = min(max(_size, a.min), a.max)
+}
+
+// uploadAdaptive uploads chunk by chunk, growing chunks on fast stable links and shrinking them after timeouts or
+// failures. Failed chunks are retried, from the server reported offset, up to Config.Retries times in a row.
+func (um *UploadMgr) uploadAdaptive() error {
+	chunker := newAdaptiveChunker(um.client.Config)
+	failures := 0
+
+	for !um.complete() && !um.aborted.Load() {
+		offset := um.offset
+		started := time.Now()
+
+		err := um.uploadChunkSized(chunker.size)
+		if err == nil {
+			failures = 0
+			chunker.success(um.offset-offset, time.Since(started))
+			continue
+		}
+
+		// a 413 for a chunk may just mean the chunk is too big, unless it is already as small as allowed
+		retryable := isRetryable(err) || (errors.Is(err, ErrLargeUpload) && chunker.size > chunker.min)
+		if !retryable || failures >= um.client.Config.Retries {
+			return err
+		}
+
+		failures++
+		chunker.failure(err)
+		slog.Warn("chunk failed, retrying with smaller chunk", "err", err, "chunkSizeBytes", chunker.size)
+
+		serverOffset, headErr := um.client.getUploadOffset(um.url)
+		if headErr != nil {
+			return errors.Join(err, headErr)
+		}
+		um.offset = serverOffset
+		um.upload.setOffset(serverOffset)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package tusc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAdaptiveChunker() *adaptiveChunker {
+	return newAdaptiveChunker(&Config{
+		ChunkSizeBytes:    1024 * 1024,
+		MinChunkSizeBytes: 256 * 1024,
+		MaxChunkSizeBytes: 8 * 1024 * 1024,
+	})
+}
+
+func TestAdaptiveChunkerGrowsWhenStable(t *testing.T) {
+	a := newTestAdaptiveChunker()
+
+	// 1 MiB in 10ms is far faster than the target duration
+	a.success(a.size, 10*time.Millisecond)
+	assert.EqualValues(t, 1024*1024, a.size, "should not grow before the link is stable")
+
+	a.success(a.size, 10*time.Millisecond)
+	assert.EqualValues(t, 2*1024*1024, a.size)
+
+	for i := 0; i < 10; i++ {
+		a.success(a.size, 10*time.Millisecond)
+	}
+	assert.EqualValues(t, 8*1024*1024, a.size, "should be capped at max")
+}
+
+func TestAdaptiveChunkerShrinks(t *testing.T) {
+	a := newTestAdaptiveChunker()
+
+	// 1 MiB in 10s is far slower than the target duration
+	a.success(a.size, 10*time.Second)
+	assert.EqualValues(t, 512*1024, a.size)
+
+	a.failure(errors.New("timeout"))
+	assert.EqualValues(t, 256*1024, a.size)
+
+	a.failure(errors.New("timeout"))
+	assert.EqualValues(t, 256*1024, a.size, "should be capped at min")
+}
+
+func TestAdaptiveChunkerLargeUploadLowersMax(t *testing.T) {
+	a := newTestAdaptiveChunker()
+
+	a.failure(ErrLargeUpload)
+	assert.EqualValues(t, 512*1024, a.size)
+	assert.EqualValues(t, 512*1024, a.max)
+
+	for i := 0; i < 10; i++ {
+		a.success(a.size, 10*time.Millisecond)
+	}
+	assert.EqualValues(t, 512*1024, a.size)
+}
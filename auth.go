@@ -0,0 +1,198 @@
+package tusc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator authorizes every request a Client sends, see Config.Authenticator
+type Authenticator interface {
+	// Authenticate adds credentials to _req, called for each request sent including re-sends
+	Authenticate(_req *http.Request) error
+	// Refresh renews credentials after _req was rejected with 401 Unauthorized. Returning true re-sends the request
+	// once, for PATCH requests the chunk is read from the source again.
+	Refresh(_req *http.Request) (bool, error)
+}
+
+// authMiddleware applies _authenticator to each request, re-sending requests without a body or with GetBody once
+// after a 401. The 401 of anything else is returned for the caller to re-send.
+func authMiddleware(_authenticator Authenticator) Middleware {
+	return func(_next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(_req *http.Request) (*http.Response, error) {
+			req := _req.Clone(_req.Context())
+			if err := _authenticator.Authenticate(req); err != nil {
+				return nil, err
+			}
+
+			res, err := _next.RoundTrip(req)
+			if err != nil || res.StatusCode != http.StatusUnauthorized {
+				return res, err
+			}
+
+			resend, err := _authenticator.Refresh(req)
+			if err != nil {
+				res.Body.Close()
+				return nil, err
+			}
+			replayable := _req.Body == nil || _req.Body == http.NoBody || _req.GetBody != nil
+			if !resend || !replayable {
+				return res, nil
+			}
+
+			io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+			res.Body.Close()
+
+			req = _req.Clone(_req.Context())
+			if _req.GetBody != nil {
+				if req.Body, err = _req.GetBody(); err != nil {
+					return nil, err
+				}
+			}
+			if err = _authenticator.Authenticate(req); err != nil {
+				return nil, err
+			}
+			return _next.RoundTrip(req)
+		})
+	}
+}
+
+// OAuth2Authenticator sends an OAuth 2.0 bearer token from TokenURL, obtained with the refresh token grant if
+// RefreshToken is set and the client credentials grant otherwise. Tokens are renewed RefreshBefore they expire, so
+// long uploads don't stall on an expired token, and after a 401.
+type OAuth2Authenticator struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// Scopes [optional] requested with the client credentials grant
+	Scopes []string
+	// RefreshToken [optional] replaced whenever the server issues a new one
+	RefreshToken string
+	// RefreshBefore how long before expiry tokens are renewed, defaults to 1 minute and at most half the lifetime of
+	// the token
+	RefreshBefore time.Duration
+	// HttpClient [optional] used for token requests, defaults to http.DefaultClient
+	HttpClient *http.Client
+
+	mu       sync.Mutex
+	token    string
+	expiry   time.Time
+	lifetime time.Duration
+}
+
+func (a *OAuth2Authenticator) Authenticate(_req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == "" || a.expiring() {
+		if err := a.fetch(_req.Context()); err != nil {
+			return err
+		}
+	}
+
+	_req.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+// Refresh fetches a new token, unless another request already replaced the one _req was rejected with
+func (a *OAuth2Authenticator) Refresh(_req *http.Request) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && _req.Header.Get("Authorization") != "Bearer "+a.token {
+		return true, nil
+	}
+	if err := a.fetch(_req.Context()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (a *OAuth2Authenticator) expiring() bool {
+	if a.expiry.IsZero() {
+		return false
+	}
+
+	refreshBefore := a.RefreshBefore
+	if refreshBefore <= 0 {
+		refreshBefore = time.Minute
+	}
+	return time.Until(a.expiry) <= min(refreshBefore, a.lifetime/2)
+}
+
+// oauth2Token a token endpoint response, RFC 6749 section 5
+type oauth2Token struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// fetch requests a new token, a.mu must be held
+func (a *OAuth2Authenticator) fetch(_ctx context.Context) error {
+	form := url.Values{}
+	if a.RefreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", a.RefreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+		if len(a.Scopes) != 0 {
+			form.Set("scope", strings.Join(a.Scopes, " "))
+		}
+	}
+
+	req, err := http.NewRequestWithContext(_ctx, http.MethodPost, a.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if a.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(a.ClientID), url.QueryEscape(a.ClientSecret))
+	}
+
+	client := a.HttpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrTokenRequest, err)
+	}
+	defer res.Body.Close()
+
+	var token oauth2Token
+	decodeErr := json.NewDecoder(io.LimitReader(res.Body, 1024*1024)).Decode(&token)
+	switch {
+	case res.StatusCode != http.StatusOK && token.Error != "":
+		return fmt.Errorf("%w: %d: %s %s", ErrTokenRequest, res.StatusCode, token.Error, token.ErrorDescription)
+	case res.StatusCode != http.StatusOK:
+		return fmt.Errorf("%w: %d", ErrTokenRequest, res.StatusCode)
+	case decodeErr != nil:
+		return fmt.Errorf("%w: %w", ErrTokenRequest, decodeErr)
+	case token.AccessToken == "":
+		return fmt.Errorf("%w: no access_token", ErrTokenRequest)
+	case !strings.EqualFold(token.TokenType, "bearer"):
+		return fmt.Errorf("%w: unsupported token_type %q", ErrTokenRequest, token.TokenType)
+	}
+
+	a.token = token.AccessToken
+	a.expiry, a.lifetime = time.Time{}, 0
+	if token.ExpiresIn > 0 {
+		a.lifetime = time.Duration(token.ExpiresIn) * time.Second
+		a.expiry = time.Now().Add(a.lifetime)
+	}
+	if token.RefreshToken != "" {
+		a.RefreshToken = token.RefreshToken
+	}
+	return nil
+}
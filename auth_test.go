@@ -0,0 +1,182 @@
+package tusc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tokenServer stands in for an OAuth 2.0 token endpoint, issuing token-1, token-2... and rotating refresh tokens
+type tokenServer struct {
+	*httptest.Server
+	expiresIn int
+
+	mu     sync.Mutex
+	issued int
+	grants []string
+	scopes []string
+}
+
+func newTokenServer(t *testing.T, _expiresIn int) *tokenServer {
+	ts := &tokenServer{expiresIn: _expiresIn}
+	ts.Server = httptest.NewServer(http.HandlerFunc(ts.serve))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (ts *tokenServer) serve(w http.ResponseWriter, r *http.Request) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	id, secret, _ := r.BasicAuth()
+	if id != "client%3Aid" || secret != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client", "error_description": "bad secret"})
+		return
+	}
+
+	grant := r.PostFormValue("grant_type")
+	if grant == "refresh_token" && r.PostFormValue("refresh_token") != fmt.Sprintf("refresh-%d", ts.issued) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	ts.issued++
+	ts.grants = append(ts.grants, grant)
+	ts.scopes = append(ts.scopes, r.PostFormValue("scope"))
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token":  fmt.Sprintf("token-%d", ts.issued),
+		"token_type":    "Bearer",
+		"expires_in":    ts.expiresIn,
+		"refresh_token": fmt.Sprintf("refresh-%d", ts.issued),
+	})
+}
+
+func (ts *tokenServer) requests() []string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]string(nil), ts.grants...)
+}
+
+func authorization(t *testing.T, _authenticator Authenticator) string {
+	req, err := http.NewRequest(http.MethodHead, "http://example.com", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, _authenticator.Authenticate(req))
+	return req.Header.Get("Authorization")
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	ts := newTokenServer(t, 3600)
+	authenticator := &OAuth2Authenticator{
+		TokenURL:     ts.URL,
+		ClientID:     "client:id",
+		ClientSecret: "secret",
+		Scopes:       []string{"upload", "read"},
+	}
+
+	assert.Equal(t, "Bearer token-1", authorization(t, authenticator))
+	assert.Equal(t, "Bearer token-1", authorization(t, authenticator))
+	assert.Equal(t, []string{"client_credentials"}, ts.requests())
+	assert.Equal(t, []string{"upload read"}, ts.scopes)
+
+	// renewed before it expires
+	authenticator.expiry = time.Now().Add(30 * time.Second)
+	assert.Equal(t, "Bearer token-2", authorization(t, authenticator))
+	assert.Len(t, ts.requests(), 2)
+}
+
+func TestOAuth2RefreshToken(t *testing.T) {
+	ts := newTokenServer(t, 3600)
+	ts.issued = 7
+	authenticator := &OAuth2Authenticator{
+		TokenURL:     ts.URL,
+		ClientID:     "client:id",
+		ClientSecret: "secret",
+		RefreshToken: "refresh-7",
+	}
+
+	assert.Equal(t, "Bearer token-8", authorization(t, authenticator))
+	assert.Equal(t, "refresh-8", authenticator.RefreshToken)
+
+	// the rotated refresh token is used next
+	req, err := http.NewRequest(http.MethodHead, "http://example.com", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer token-8")
+	resend, err := authenticator.Refresh(req)
+	assert.Nil(t, err)
+	assert.True(t, resend)
+	assert.Equal(t, "Bearer token-9", authorization(t, authenticator))
+	assert.Equal(t, []string{"refresh_token", "refresh_token"}, ts.requests())
+
+	// a request rejected with a token already replaced doesn't fetch another
+	resend, err = authenticator.Refresh(req)
+	assert.Nil(t, err)
+	assert.True(t, resend)
+	assert.Len(t, ts.requests(), 2)
+}
+
+func TestOAuth2Errors(t *testing.T) {
+	ts := newTokenServer(t, 3600)
+
+	authenticator := &OAuth2Authenticator{TokenURL: ts.URL, ClientID: "client:id", ClientSecret: "wrong"}
+	req, err := http.NewRequest(http.MethodHead, "http://example.com", nil)
+	assert.Nil(t, err)
+	err = authenticator.Authenticate(req)
+	assert.ErrorIs(t, err, ErrTokenRequest)
+	assert.ErrorContains(t, err, "invalid_client")
+
+	for _, body := range []string{`{"access_token": "a", "token_type": "mac"}`, `{"token_type": "bearer"}`, `not json`} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, body)
+		}))
+		authenticator = &OAuth2Authenticator{TokenURL: server.URL}
+		assert.ErrorIs(t, authenticator.Authenticate(req), ErrTokenRequest, body)
+		server.Close()
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	ts := newTokenServer(t, 3600)
+	authenticator := &OAuth2Authenticator{TokenURL: ts.URL, ClientID: "client:id", ClientSecret: "secret"}
+
+	// only the second token is accepted
+	var sent []string
+	next := RoundTripperFunc(func(_req *http.Request) (*http.Response, error) {
+		sent = append(sent, _req.Method+" "+_req.Header.Get("Authorization"))
+		status := http.StatusUnauthorized
+		if _req.Header.Get("Authorization") == "Bearer token-2" {
+			status = http.StatusNoContent
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	transport := authMiddleware(authenticator)(next)
+
+	req, err := http.NewRequest(http.MethodHead, "http://example.com", nil)
+	assert.Nil(t, err)
+	res, err := transport.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Equal(t, []string{"HEAD Bearer token-1", "HEAD Bearer token-2"}, sent)
+	assert.Empty(t, req.Header.Get("Authorization"), "the caller's request is left untouched")
+
+	// a body that can't be replayed is left to the caller, with the credentials already refreshed
+	authenticator.token = "token-0"
+	sent = nil
+	req, err = http.NewRequest(http.MethodPatch, "http://example.com", io.MultiReader(strings.NewReader("chunk")))
+	assert.Nil(t, err)
+	res, err = transport.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	assert.Equal(t, []string{"PATCH Bearer token-0"}, sent)
+	assert.Equal(t, "Bearer token-3", authorization(t, authenticator))
+}
@@ -0,0 +1,314 @@
+package tusc
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ManifestFormat int
+
+const (
+	// ManifestJSONL one JSON encoded ManifestEntry per line
+	ManifestJSONL ManifestFormat = iota
+	// ManifestCSV a header row naming the columns, "path" is required, "fingerprint" and "priority" are optional and
+	//   any other column is metadata
+	ManifestCSV
+)
+
+// ManifestFormatFromPath ManifestCSV for ".csv" files, ManifestJSONL otherwise
+func ManifestFormatFromPath(_path string) ManifestFormat {
+	if strings.EqualFold(filepath.Ext(_path), ".csv") {
+		return ManifestCSV
+	}
+	return ManifestJSONL
+}
+
+// ManifestEntry a file to upload in a batch
+type ManifestEntry struct {
+	Path string `json:"path"`
+	// Fingerprint [optional] generated by BatchOptions.Fingerprinter if unset
+	Fingerprint string   `json:"fingerprint,omitempty"`
+	Metadata    Metadata `json:"metadata,omitempty"`
+	Priority    int      `json:"priority,omitempty"`
+}
+
+// ReadManifest parses a manifest, blank JSONL lines are skipped
+func ReadManifest(_reader io.Reader, _format ManifestFormat) ([]ManifestEntry, error) {
+	switch _format {
+	case ManifestJSONL:
+		return readManifestJSONL(_reader)
+	case ManifestCSV:
+		return readManifestCSV(_reader)
+	default:
+		return nil, ErrBadManifest
+	}
+}
+
+func readManifestJSONL(_reader io.Reader) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+
+	scanner := bufio.NewScanner(_reader)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var entry ManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrBadManifest, line, err)
+		}
+		if entry.Path == "" {
+			return nil, fmt.Errorf("%w: line %d: no path", ErrBadManifest, line)
+		}
+		if err := entry.Metadata.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrBadManifest, line, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+func readManifestCSV(_reader io.Reader) ([]ManifestEntry, error) {
+	reader := csv.NewReader(_reader)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadManifest, err)
+	}
+
+	pathColumn := -1
+	for i, name := range header {
+		if name == "path" {
+			pathColumn = i
+		}
+	}
+	if pathColumn < 0 {
+		return nil, fmt.Errorf(`%w: no "path" column`, ErrBadManifest)
+	}
+
+	var entries []ManifestEntry
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrBadManifest, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		entry := ManifestEntry{Metadata: make(Metadata)}
+		for i, value := range record {
+			switch header[i] {
+			case "path":
+				entry.Path = value
+			case "fingerprint":
+				entry.Fingerprint = value
+			case "priority":
+				if value == "" {
+					continue
+				}
+				if entry.Priority, err = strconv.Atoi(value); err != nil {
+					return nil, fmt.Errorf("%w: line %d: priority: %w", ErrBadManifest, line, err)
+				}
+			default:
+				if value != "" {
+					entry.Metadata[header[i]] = value
+				}
+			}
+		}
+		if entry.Path == "" {
+			return nil, fmt.Errorf("%w: line %d: no path", ErrBadManifest, line)
+		}
+		if err := entry.Metadata.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrBadManifest, line, err)
+		}
+
+		entries = append(entries, entry)
+	}
+}
+
+// BatchOptions control Client.UploadBatch
+type BatchOptions struct {
+	// Workers number of files uploaded concurrently, defaults to 1
+	Workers int
+	// Fingerprinter [optional] for entries without a fingerprint, defaults to Config.Fingerprinter, or
+	//   FileInfoFingerprinter if that is unset
+	Fingerprinter Fingerprinter
+	// Checksum [optional] hash of each file recorded in the report, defaults to sha256
+	Checksum func() hash.Hash
+	// ChecksumAlg name of Checksum, reported alongside each checksum
+	ChecksumAlg string
+	// OnProgress [optional] called every ProgressInterval while uploading
+	OnProgress func(QueueProgress)
+	// ProgressInterval defaults to 1 second
+	ProgressInterval time.Duration
+}
+
+// BatchResult the outcome of a single manifest entry
+type BatchResult struct {
+	Path        string `json:"path"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	URL         string `json:"url,omitempty"`
+	State       string `json:"state"`
+	Size        int64  `json:"size"`
+	// Offset bytes on the server, equal to Size once succeeded
+	Offset      int64  `json:"offset"`
+	ChecksumAlg string `json:"checksumAlgorithm,omitempty"`
+	// Checksum hex encoded digest of the file content
+	Checksum string `json:"checksum,omitempty"`
+	// Duration in nanoseconds
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// BatchReport the outcome of Client.UploadBatch, results are in manifest order
+type BatchReport struct {
+	Results       []BatchResult `json:"results"`
+	Succeeded     int           `json:"succeeded"`
+	Failed        int           `json:"failed"`
+	Cancelled     int           `json:"cancelled"`
+	BytesUploaded int64         `json:"bytesUploaded"`
+	// Duration in nanoseconds
+	Duration time.Duration `json:"duration"`
+}
+
+// UploadBatch uploads every manifest entry with bounded concurrency, resuming uploads recorded in Config.Store.
+// Failures are recorded in the report rather than stopping the batch, an error is only returned if the batch
+// could not start. Cancelling _ctx cancels every remaining upload.
+func (c *Client) UploadBatch(_ctx context.Context, _entries []ManifestEntry, _options *BatchOptions) (*BatchReport, error) {
+	if _options == nil {
+		_options = &BatchOptions{}
+	}
+
+	fingerprinter := _options.Fingerprinter
+	if fingerprinter == nil {
+		fingerprinter = c.Config.Fingerprinter
+	}
+	if fingerprinter == nil {
+		fingerprinter = &FileInfoFingerprinter{}
+	}
+
+	newHash, checksumAlg := _options.Checksum, _options.ChecksumAlg
+	if newHash == nil {
+		newHash, checksumAlg = sha256.New, "sha256"
+	}
+
+	queue, err := NewUploadQueue(c, max(_options.Workers, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BatchReport{Results: make([]BatchResult, len(_entries))}
+	items := make([]*QueueItem, len(_entries))
+	for i, entry := range _entries {
+		result := &report.Results[i]
+		result.Path = entry.Path
+		result.ChecksumAlg = checksumAlg
+
+		items[i], err = queue.AddFunc(func() (*Upload, error) {
+			upload, checksum, err := openManifestEntry(entry, fingerprinter, newHash)
+			// each result is only written by the worker running its item, and read after Wait
+			result.Checksum = checksum
+			return upload, err
+		}, entry.Priority)
+		if err != nil {
+			queue.CancelAll()
+			queue.Wait()
+			return nil, err
+		}
+	}
+
+	stop := context.AfterFunc(_ctx, queue.CancelAll)
+	defer stop()
+
+	var summary QueueSummary
+	if _options.OnProgress == nil {
+		summary = queue.Wait()
+	} else {
+		stopReporting := reportProgress(queue, _options.OnProgress, _options.ProgressInterval)
+		summary = queue.Wait()
+		stopReporting()
+	}
+
+	for i, item := range items {
+		result := &report.Results[i]
+		result.Fingerprint = item.Fingerprint()
+		result.URL = item.URL()
+		result.State = item.State().String()
+		result.Size = item.Size()
+		result.Offset = item.Offset()
+		result.Duration = item.Duration()
+		if err := item.Err(); err != nil {
+			result.Error = err.Error()
+		}
+		if item.State() != QueueItemSucceeded {
+			result.ChecksumAlg, result.Checksum = "", ""
+		}
+	}
+
+	report.Succeeded = len(summary.Succeeded)
+	report.Failed = len(summary.Failed)
+	report.Cancelled = len(summary.Cancelled)
+	report.BytesUploaded = summary.BytesUploaded
+	report.Duration = summary.Duration
+
+	return report, nil
+}
+
+// openManifestEntry opens the file, hashing its content for the report
+func openManifestEntry(_entry ManifestEntry, _fingerprinter Fingerprinter, _newHash func() hash.Hash) (*Upload, string, error) {
+	file, err := os.Open(_entry.Path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	upload, err := openManifestUpload(file, _entry, _fingerprinter)
+	if err != nil {
+		file.Close()
+		return nil, "", err
+	}
+
+	hasher := _newHash()
+	if _, err = io.Copy(hasher, io.NewSectionReader(file, 0, upload.Size())); err != nil {
+		file.Close()
+		return nil, "", err
+	}
+
+	return upload, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func openManifestUpload(_file *os.File, _entry ManifestEntry, _fingerprinter Fingerprinter) (*Upload, error) {
+	upload, err := NewUploadFromFile(_file, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// metadata first, MetadataFingerprinter may depend on it
+	for k, v := range _entry.Metadata {
+		upload.Metadata[k] = v
+	}
+
+	if _entry.Fingerprint != "" {
+		upload.Fingerprint = _entry.Fingerprint
+	} else if err = upload.GenerateFingerprint(_fingerprinter); err != nil {
+		return nil, err
+	}
+
+	return upload, nil
+}
@@ -0,0 +1,59 @@
+package tusc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadManifestJSONL(t *testing.T) {
+	manifest := `{"path": "a.txt", "metadata": {"team": "ops"}}
+
+{"path": "b.txt", "fingerprint": "fp-b", "priority": 2}
+`
+	entries, err := ReadManifest(strings.NewReader(manifest), ManifestJSONL)
+	assert.Nil(t, err)
+	assert.Equal(t, []ManifestEntry{
+		{Path: "a.txt", Metadata: Metadata{"team": "ops"}},
+		{Path: "b.txt", Fingerprint: "fp-b", Priority: 2},
+	}, entries)
+
+	_, err = ReadManifest(strings.NewReader(`{"path": "a.txt"}`+"\n{"), ManifestJSONL)
+	assert.ErrorIs(t, err, ErrBadManifest)
+	assert.ErrorContains(t, err, "line 2")
+
+	_, err = ReadManifest(strings.NewReader(`{"fingerprint": "x"}`), ManifestJSONL)
+	assert.ErrorIs(t, err, ErrBadManifest)
+
+	_, err = ReadManifest(strings.NewReader(`{"path": "a.txt", "metadata": {"file name": "a"}}`), ManifestJSONL)
+	assert.ErrorIs(t, err, ErrBadManifest)
+	assert.ErrorIs(t, err, ErrBadMetadata)
+}
+
+func TestReadManifestCSV(t *testing.T) {
+	manifest := "path,fingerprint,priority,team\na.txt,,,ops\n\"b,c.txt\",fp-b,2,\n"
+	entries, err := ReadManifest(strings.NewReader(manifest), ManifestCSV)
+	assert.Nil(t, err)
+	assert.Equal(t, []ManifestEntry{
+		{Path: "a.txt", Metadata: Metadata{"team": "ops"}},
+		{Path: "b,c.txt", Fingerprint: "fp-b", Priority: 2, Metadata: Metadata{}},
+	}, entries)
+
+	for _, manifest := range []string{
+		"file\na.txt\n",
+		"path,priority\na.txt,high\n",
+		"path,team\n,ops\n",
+		"path\na.txt,extra\n",
+		"path,file name\na.txt,a\n",
+	} {
+		_, err = ReadManifest(strings.NewReader(manifest), ManifestCSV)
+		assert.ErrorIs(t, err, ErrBadManifest, manifest)
+	}
+}
+
+func TestManifestFormatFromPath(t *testing.T) {
+	assert.Equal(t, ManifestCSV, ManifestFormatFromPath("files.CSV"))
+	assert.Equal(t, ManifestJSONL, ManifestFormatFromPath("files.jsonl"))
+	assert.Equal(t, ManifestJSONL, ManifestFormatFromPath("-"))
+}
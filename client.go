@@ -1,6 +1,7 @@
 package tusc
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -26,6 +27,7 @@ type Option struct {
 	creationDeferLength bool
 	creationWithUpload  bool
 	expiration          bool
+	termination         bool
 	// max upload size
 	maxSizeBytes int64
 }
@@ -55,13 +57,17 @@ func NewClient(_baseUrl string, _config *Config) (*Client, error) {
 		Version: ProtocolVersion,
 	}
 
-	if err := client.options(); err != nil {
+	option, err := client.options()
+	if err != nil {
 		return nil, err
 	}
+	client.Option = option
 
 	return client, nil
 }
 
+// Do sends a request with Config.Header and method overrides applied, through Config.Middleware,
+// Config.Authenticator and Config.Signer
 func (c *Client) Do(_req *http.Request) (*http.Response, error) {
 	for k, v := range c.Config.Header {
 		_req.Header[k] = v
@@ -69,73 +75,138 @@ func (c *Client) Do(_req *http.Request) (*http.Response, error) {
 
 	_req.Header.Set("Tus-Resumable", ProtocolVersion)
 
-	if c.Config.HTTPMethodOverrides == nil {
-		return c.Config.HttpClient.Do(_req)
-	} else if method, ok := (*c.Config.HTTPMethodOverrides)[_req.Method]; ok {
-		_req.Header.Set("X-HTTP-Method-Override", _req.Method)
-		_req.Method = method
+	if c.Config.HTTPMethodOverrides != nil {
+		if method, ok := (*c.Config.HTTPMethodOverrides)[_req.Method]; ok {
+			_req.Header.Set("X-HTTP-Method-Override", _req.Method)
+			_req.Method = method
+		}
 	}
 
-	return c.Config.HttpClient.Do(_req)
+	// authentication and signing are innermost so re-sends are authenticated and signed afresh, and middleware sees
+	//   the final response
+	var transport http.RoundTripper = RoundTripperFunc(c.Config.HttpClient.Do)
+	if c.Config.Signer != nil {
+		transport = signMiddleware(c.Config.Signer)(transport)
+	}
+	if c.Config.Authenticator != nil {
+		transport = authMiddleware(c.Config.Authenticator)(transport)
+	}
+
+	return ChainMiddleware(c.Config.Middleware...)(transport).RoundTrip(_req)
 }
 
-func (c *Client) options() error {
+// options sends an OPTIONS request and parses the extensions, a nil Option if the server does not answer OPTIONS
+func (c *Client) options() (*Option, error) {
 	req, err := http.NewRequest(http.MethodOptions, c.BaseUrl, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	res, err := c.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
 		slog.Warn("options unsupported or unreachable, extensions may fail without warning")
-		return nil
+		return nil, nil
 	}
 
 	if tusVersions := res.Header.Get("Tus-Versions"); tusVersions != "" && !slices.Contains(commaSplitTrim(tusVersions), ProtocolVersion) {
-		return fmt.Errorf("unsupported tus version: '%s', server supports: %s", ProtocolVersion, tusVersions)
+		return nil, fmt.Errorf("unsupported tus version: '%s', server supports: %s", ProtocolVersion, tusVersions)
 	}
 
-	c.Option = &Option{}
+	option := &Option{}
 	extensions := commaSplitTrim(res.Header.Get("Tus-Extension"))
 	for _, extension := range extensions {
 		switch strings.ToLower(extension) {
 		case "concatenation":
-			c.Option.concatenation = true
+			option.concatenation = true
 		case "creation":
-			c.Option.creation = true
+			option.creation = true
 		case "creation-defer-length":
-			c.Option.creationDeferLength = true
+			option.creationDeferLength = true
 		case "creation-with-upload":
-			c.Option.creationWithUpload = true
+			option.creationWithUpload = true
 		case "expiration":
-			c.Option.expiration = true
+			option.expiration = true
 		case "checksum":
 			if c.Config.ChecksumAlg == "" {
 				continue
 			}
 			algorithms := commaSplitTrim(res.Header.Get("Tus-Checksum-Algorithm"))
 			if !slices.Contains(algorithms, c.Config.ChecksumAlg) {
-				return errors.New("ChecksumAlgName " + c.Config.ChecksumAlg + " not supported")
+				return nil, errors.New("ChecksumAlgName " + c.Config.ChecksumAlg + " not supported")
 			}
-			c.Option.checksum = true
+			option.checksum = true
 		case "checksum-trailer":
-			c.Option.checksumTrailer = true
+			option.checksumTrailer = true
 		case "termination":
+			option.termination = true
 		default:
-			return errors.New("unknown extension: " + extension)
+			return nil, errors.New("unknown extension: " + extension)
 		}
 	}
 
 	if maxSizeBytes := res.Header.Get("Tus-Max-Size"); maxSizeBytes != "" {
-		c.Option.maxSizeBytes, err = strconv.ParseInt(maxSizeBytes, 10, 64)
-		return err
+		if option.maxSizeBytes, err = strconv.ParseInt(maxSizeBytes, 10, 64); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	return option, nil
+}
+
+// Capabilities what a server reports in response to OPTIONS
+type Capabilities struct {
+	// Versions protocol versions supported, from Tus-Version
+	Versions []string `json:"versions"`
+	// Extensions as listed in Tus-Extension
+	Extensions []string `json:"extensions"`
+	// MaxSize largest upload accepted in bytes, 0 if unlimited or unreported
+	MaxSize int64 `json:"maxSize,omitempty"`
+	// ChecksumAlgorithms supported by the checksum extension
+	ChecksumAlgorithms []string `json:"checksumAlgorithms,omitempty"`
+}
+
+// Capabilities sends an OPTIONS request and returns the server's capabilities as reported, unlike the Option
+// recorded by NewClient no extension is rejected
+func (c *Client) Capabilities(_ctx context.Context) (*Capabilities, error) {
+	req, err := http.NewRequestWithContext(_ctx, http.MethodOptions, c.BaseUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		return nil, newClientError(res)
+	}
+
+	capabilities := &Capabilities{
+		Versions:           commaSplitTrim(res.Header.Get("Tus-Version")),
+		Extensions:         commaSplitTrim(res.Header.Get("Tus-Extension")),
+		ChecksumAlgorithms: commaSplitTrim(res.Header.Get("Tus-Checksum-Algorithm")),
+	}
+	if maxSize := res.Header.Get("Tus-Max-Size"); maxSize != "" {
+		if capabilities.MaxSize, err = strconv.ParseInt(maxSize, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	return capabilities, nil
+}
+
+// Probe checks the server is reachable by repeating the OPTIONS request. The options recorded by NewClient are left
+// as is, uploads in progress read them.
+func (c *Client) Probe() error {
+	_, err := c.options()
+	return err
 }
 
 func commaSplitTrim(_s string) []string {
@@ -158,6 +229,25 @@ func (c *Client) CreateUpload(_upload *Upload) (*UploadMgr, error) {
 	if _upload == nil {
 		return nil, ErrNilUpload
 	}
+	// a fingerprint is only needed to resume later, creating without one is allowed as before
+	if len(_upload.Fingerprint) == 0 && c.Config.Fingerprinter != nil {
+		if err := _upload.GenerateFingerprint(c.Config.Fingerprinter); err != nil {
+			return nil, err
+		}
+	}
+	if err := enrichMetadata(_upload, c.Config.MetadataEnrichers); err != nil {
+		return nil, err
+	}
+	if integrity := c.Config.Integrity; integrity != nil && integrity.DeclareInMetadata {
+		newHash, algorithm := integrity.hash()
+		if err := (&ChecksumEnricher{Hash: newHash, Algorithm: algorithm}).Enrich(_upload); err != nil {
+			return nil, err
+		}
+	}
+	metadata, err := encodeMetadataLimit(_upload.Metadata, c.Config.MaxMetadataBytes)
+	if err != nil {
+		return nil, err
+	}
 
 	req, err := http.NewRequest(http.MethodPost, c.BaseUrl, nil)
 	if err != nil {
@@ -165,8 +255,17 @@ func (c *Client) CreateUpload(_upload *Upload) (*UploadMgr, error) {
 	}
 
 	req.Header.Set("Content-Length", "0")
-	req.Header.Set("Upload-Length", strconv.FormatInt(_upload.size, 10))
-	req.Header.Set("Upload-Metadata", _upload.EncodedMetadata())
+	if _upload.size < 0 {
+		if c.Option != nil && !c.Option.creationDeferLength {
+			return nil, ErrExtensionNotAvailable
+		}
+		req.Header.Set("Upload-Defer-Length", "1")
+	} else {
+		req.Header.Set("Upload-Length", strconv.FormatInt(_upload.size, 10))
+	}
+	if metadata != "" {
+		req.Header.Set("Upload-Metadata", metadata)
+	}
 
 	res, err := c.Do(req)
 	if err != nil {
@@ -183,7 +282,9 @@ func (c *Client) CreateUpload(_upload *Upload) (*UploadMgr, error) {
 			return nil, err
 		}
 
-		c.Config.Store.Set(_upload.Fingerprint, url.String())
+		if len(_upload.Fingerprint) != 0 {
+			c.Config.Store.Set(_upload.Fingerprint, url.String())
+		}
 
 		return NewUploadMgr(c, url.String(), _upload, 0)
 	case http.StatusPreconditionFailed:
@@ -196,31 +297,44 @@ func (c *Client) CreateUpload(_upload *Upload) (*UploadMgr, error) {
 }
 
 func (c *Client) getUploadOffset(_url string) (int64, error) {
-	req, err := http.NewRequest("HEAD", _url, nil)
+	header, err := c.headUpload(context.Background(), _url)
 	if err != nil {
 		return 0, err
 	}
+	return strconv.ParseInt(header.Get("Upload-Offset"), 10, 64)
+}
+
+// GetUploadInfo fetches the state of an upload with HEAD
+func (c *Client) GetUploadInfo(_ctx context.Context, _url string) (*UploadInfo, error) {
+	header, err := c.headUpload(_ctx, _url)
+	if err != nil {
+		return nil, err
+	}
+	return parseUploadInfo(_url, header)
+}
+
+func (c *Client) headUpload(_ctx context.Context, _url string) (http.Header, error) {
+	req, err := http.NewRequestWithContext(_ctx, http.MethodHead, _url, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	res, err := c.Do(req)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer res.Body.Close()
 
 	switch res.StatusCode {
-	case http.StatusOK:
-		i, err := strconv.ParseInt(res.Header.Get("Upload-Offset"), 10, 64)
-		if err == nil {
-			return i, nil
-		}
-		return 0, err
+	case http.StatusOK, http.StatusNoContent:
+		return res.Header, nil
 	case http.StatusForbidden, http.StatusNotFound, http.StatusGone:
 		// upload doesn't exist
-		return 0, ErrUploadNotFound
+		return nil, ErrUploadNotFound
 	case http.StatusPreconditionFailed:
-		return 0, ErrVersionMismatch
+		return nil, ErrVersionMismatch
 	default:
-		return 0, newClientError(res)
+		return nil, newClientError(res)
 	}
 }
 
@@ -228,8 +342,8 @@ func (c *Client) ResumeUpload(_upload *Upload) (*UploadMgr, error) {
 	if _upload == nil {
 		return nil, ErrNilUpload
 	}
-	if len(_upload.Fingerprint) == 0 {
-		return nil, ErrFingerprintUnset
+	if err := c.resolveFingerprint(_upload); err != nil {
+		return nil, err
 	}
 	url, found := c.Config.Store.Get(_upload.Fingerprint)
 	if !found {
@@ -262,18 +376,69 @@ func (c *Client) CreateOrResumeUpload(_upload *Upload) (*UploadMgr, error) {
 	return nil, err
 }
 
-func (c *Client) uploadChunk(_url string, _buf io.Reader, _checksum string, _size int64, _offset int64) (int64, error) {
+// TerminateUpload deletes an upload from the server with the termination extension. Store entries are left as is,
+// a later resume finds the upload gone and creates a new one.
+func (c *Client) TerminateUpload(_ctx context.Context, _url string) error {
+	if c.Option != nil && !c.Option.termination {
+		return ErrExtensionNotAvailable
+	}
+
+	req, err := http.NewRequestWithContext(_ctx, http.MethodDelete, _url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusNotFound, http.StatusGone, http.StatusForbidden:
+		return ErrUploadNotFound
+	case http.StatusPreconditionFailed:
+		return ErrVersionMismatch
+	default:
+		return newClientError(res)
+	}
+}
+
+// resolveFingerprint generates a fingerprint with Config.Fingerprinter if the upload does not have one
+func (c *Client) resolveFingerprint(_upload *Upload) error {
+	if len(_upload.Fingerprint) != 0 {
+		return nil
+	}
+	if c.Config.Fingerprinter == nil {
+		return ErrFingerprintUnset
+	}
+
+	return _upload.GenerateFingerprint(c.Config.Fingerprinter)
+}
+
+// uploadChunk sends a PATCH. _uploadLength >= 0 declares the final length of a deferred length upload.
+func (c *Client) uploadChunk(_ctx context.Context, _url string, _buf io.Reader, _checksum string, _size int64, _offset int64, _uploadLength int64) (int64, error) {
 
-	req, err := http.NewRequest(http.MethodPatch, _url, _buf)
+	req, err := http.NewRequestWithContext(_ctx, http.MethodPatch, _url, _buf)
 	if err != nil {
 		return -1, err
 	}
 
+	// only bytes/strings readers have their length detected, anything else would be sent chunked.
+	//   a negative _size deliberately sends the body chunked.
+	req.ContentLength = _size
 	req.Header.Set("Content-Type", "application/offset+octet-stream")
-	req.Header.Set("Content-Length", strconv.FormatInt(_size, 10))
+	if _size >= 0 {
+		req.Header.Set("Content-Length", strconv.FormatInt(_size, 10))
+	}
 	req.Header.Set("Upload-Offset", strconv.FormatInt(_offset, 10))
-	if c.Option != nil && c.Option.checksum {
-		req.Header.Set("Tus-Checksum-Algorithm", _checksum)
+	if _uploadLength >= 0 {
+		req.Header.Set("Upload-Length", strconv.FormatInt(_uploadLength, 10))
+	}
+	if c.Option != nil && c.Option.checksum && _checksum != "" {
+		req.Header.Set("Upload-Checksum", _checksum)
 	}
 
 	res, err := c.Do(req)
@@ -295,6 +460,8 @@ func (c *Client) uploadChunk(_url string, _buf io.Reader, _checksum string, _siz
 		return 0, ErrVersionMismatch
 	case http.StatusRequestEntityTooLarge:
 		return 0, ErrLargeUpload
+	case http.StatusUnauthorized:
+		return 0, fmt.Errorf("%w: %w", ErrUnauthorized, newClientError(res))
 	default:
 		return 0, newClientError(res)
 	}
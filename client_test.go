@@ -1,14 +1,22 @@
 package tusc
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httputil"
 	netUrl "net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -234,7 +242,7 @@ func (s *UploadTestSuite) TestResumeUpload() {
 		uploadMgr.Abort()
 	}()
 	// this test will fail if the upload completes too quickly, thus we use a (relatively) huge 1GB file
-	s.Equalf(false, uploadMgr.aborted, "Expected uploadMgr.aborted to be %v but got %v", false, uploadMgr.aborted)
+	s.Equalf(false, uploadMgr.aborted.Load(), "Expected uploadMgr.aborted to be %v but got %v", false, uploadMgr.aborted.Load())
 
 	err = uploadMgr.Upload()
 	s.Equalf(nil, err, "Expected uploadMgr.Upload() to be %v but got %v", nil, err)
@@ -242,7 +250,7 @@ func (s *UploadTestSuite) TestResumeUpload() {
 	uploadPercentage := uploadMgr.upload.Progress()
 	// if upload percentage is 100, aborting is impossible, so this test is run first
 	s.NotEqualf(100, uploadPercentage, "Expected upload percentage to be < 100 but got %v", uploadPercentage)
-	s.Equalf(true, uploadMgr.aborted, "Expected uploadMgr.aborted to be %v but got %v", true, uploadMgr.aborted)
+	s.Equalf(true, uploadMgr.aborted.Load(), "Expected uploadMgr.aborted to be %v but got %v", true, uploadMgr.aborted.Load())
 
 	uploadMgr, err = client.ResumeUpload(upload)
 	s.Nil(err)
@@ -364,12 +372,11 @@ func (s *UploadTestSuite) TestUploadWithChecksum() {
 	err = f.Truncate(exampleFileSize)
 	s.Nil(err)
 
-	hasher := sha1.New()
 	config := &Config{
 		ChunkSizeBytes: 5 * 1024 * 1024,
 		Store:          NewMemoryStore(),
 		ChecksumAlg:    "sha1",
-		ChecksumFunc:   &hasher,
+		ChecksumFunc:   sha1.New,
 	}
 
 	client, err := NewClient(s.url, config)
@@ -395,6 +402,1139 @@ func (s *UploadTestSuite) TestUploadWithChecksum() {
 	s.EqualValues(exampleFileSize, fi.Size)
 }
 
+// checksumTransport advertises the checksum extension, which tusd does not implement, and records whether the
+// Upload-Checksum header of every PATCH matches its body
+type checksumTransport struct {
+	mu         sync.Mutex
+	checksums  []string
+	mismatches int
+}
+
+func (ct *checksumTransport) RoundTrip(_req *http.Request) (*http.Response, error) {
+	if _req.Method == http.MethodPatch {
+		body, err := io.ReadAll(_req.Body)
+		if err != nil {
+			return nil, err
+		}
+		_req.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha1.Sum(body)
+		checksum := _req.Header.Get("Upload-Checksum")
+		ct.mu.Lock()
+		ct.checksums = append(ct.checksums, checksum)
+		if checksum != "sha1 "+base64.StdEncoding.EncodeToString(sum[:]) {
+			ct.mismatches++
+		}
+		ct.mu.Unlock()
+	}
+
+	res, err := http.DefaultTransport.RoundTrip(_req)
+	if err == nil && _req.Method == http.MethodOptions {
+		res.Header.Set("Tus-Extension", res.Header.Get("Tus-Extension")+",checksum")
+		res.Header.Set("Tus-Checksum-Algorithm", "md5,sha1")
+	}
+	return res, err
+}
+
+func (s *UploadTestSuite) TestUploadChecksumHeader() {
+	transport := &checksumTransport{}
+
+	cfg := DefaultConfig()
+	cfg.ChunkSizeBytes = 4
+	cfg.ChecksumAlg = "sha1"
+	cfg.ChecksumFunc = sha1.New
+	cfg.HttpClient = &http.Client{Transport: transport}
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	fingerprint := "fingerprint-TestUploadChecksumHeader"
+	upload, err := NewUploadFromBytes([]byte("1234567890"), &fingerprint)
+	s.Nil(err)
+
+	uploadMgr, err := client.CreateUpload(upload)
+	s.Nil(err)
+	s.Nil(uploadMgr.Upload())
+	s.Len(transport.checksums, 3)
+	s.Zero(transport.mismatches)
+}
+
+func (s *UploadTestSuite) TestUploadQueueChecksums() {
+	transport := &checksumTransport{}
+
+	cfg := DefaultConfig()
+	cfg.ChunkSizeBytes = 1024
+	cfg.ChecksumAlg = "sha1"
+	cfg.ChecksumFunc = sha1.New
+	cfg.HttpClient = &http.Client{Transport: transport}
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	queue, err := NewUploadQueue(client, 4)
+	s.Nil(err)
+
+	const uploads = 8
+	for i := 0; i < uploads; i++ {
+		fingerprint := "fingerprint-TestUploadQueueChecksums-" + strconv.Itoa(i)
+		upload, err := NewUploadFromBytes(bytes.Repeat([]byte{byte(i)}, 16*1024), &fingerprint)
+		s.Nil(err)
+
+		_, err = queue.Add(upload, 0)
+		s.Nil(err)
+	}
+
+	summary := queue.Wait()
+	s.Len(summary.Succeeded, uploads)
+	s.Len(transport.checksums, uploads*16)
+	s.Zero(transport.mismatches)
+}
+
+// readerAtOnly hides every method but ReadAt
+type readerAtOnly struct {
+	r io.ReaderAt
+}
+
+func (r readerAtOnly) ReadAt(p []byte, off int64) (int, error) {
+	return r.r.ReadAt(p, off)
+}
+
+// readSeekerOnly hides ReadAt, forcing the buffered chunk path
+type readSeekerOnly struct {
+	rs io.ReadSeeker
+}
+
+func (r *readSeekerOnly) Read(p []byte) (int, error) {
+	return r.rs.Read(p)
+}
+
+func (r *readSeekerOnly) Seek(offset int64, whence int) (int64, error) {
+	return r.rs.Seek(offset, whence)
+}
+
+func (s *UploadTestSuite) TestUploadSources() {
+	content := bytes.Repeat([]byte("0123456789"), 1024)
+
+	cfg := DefaultConfig()
+	cfg.ChunkSizeBytes = 1000
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	fingerprint := "fingerprint-TestUploadSources-ReaderAt"
+	readerAtUpload, err := NewUploadFromReaderAt(readerAtOnly{bytes.NewReader(content)}, int64(len(content)), nil, &fingerprint)
+	s.Nil(err)
+
+	fingerprint = "fingerprint-TestUploadSources-ReadSeeker"
+	readSeekerUpload, err := NewUpload(&readSeekerOnly{bytes.NewReader(content)}, int64(len(content)), nil, &fingerprint)
+	s.Nil(err)
+	s.Nil(readSeekerUpload.readerAt)
+
+	for _, upload := range []*Upload{readerAtUpload, readSeekerUpload} {
+		uploadMgr, err := client.CreateUpload(upload)
+		s.Nil(err)
+
+		err = uploadMgr.Upload()
+		s.Nil(err)
+
+		s.Equal(content, s.uploadedContent(uploadMgr.url))
+	}
+}
+
+// flakyReaderAt fails a single read once position failAt is reached
+type flakyReaderAt struct {
+	r      io.ReaderAt
+	failAt int64
+	failed bool
+}
+
+func (r *flakyReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if !r.failed && off+int64(len(p)) > r.failAt {
+		r.failed = true
+		n, _ := r.r.ReadAt(p[:max(0, r.failAt-off)], off)
+		return n, errors.New("flaky read")
+	}
+	return r.r.ReadAt(p, off)
+}
+
+func (s *UploadTestSuite) TestStreamingUpload() {
+	content := bytes.Repeat([]byte("0123456789"), 100*1024)
+
+	cfg := DefaultConfig()
+	cfg.UploadMode = UploadModeStreaming
+	cfg.StreamCheckpointBytes = 64 * 1024
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	s.T().Run("single patch", func(t *testing.T) {
+		// slow the upload down so checkpoints happen before the server has every byte
+		client.Config.RateLimiter = NewRateLimiter(int64(len(content)) / 2)
+		defer func() { client.Config.RateLimiter = nil }()
+
+		fingerprint := "fingerprint-TestStreamingUpload"
+		upload, err := NewUploadFromBytes(content, &fingerprint)
+		s.Nil(err)
+
+		uploadMgr, err := client.CreateUpload(upload)
+		s.Nil(err)
+
+		progress := make(chan Upload, 64)
+		uploadMgr.Subscribe(progress)
+
+		err = uploadMgr.Upload()
+		s.Nil(err)
+		s.Equal(content, s.uploadedContent(uploadMgr.url))
+		s.Greater(len(progress), 1)
+
+		// checkpoints are offsets acknowledged by the server, so never move backwards
+		last := int64(0)
+		for len(progress) > 0 {
+			u := <-progress
+			s.GreaterOrEqual(u.Offset(), last)
+			last = u.Offset()
+		}
+	})
+
+	s.T().Run("resumes after interruption", func(t *testing.T) {
+		fingerprint := "fingerprint-TestStreamingUpload-Interrupted"
+		source := &flakyReaderAt{r: bytes.NewReader(content), failAt: int64(len(content) / 2)}
+		upload, err := NewUploadFromReaderAt(source, int64(len(content)), nil, &fingerprint)
+		s.Nil(err)
+
+		uploadMgr, err := client.CreateUpload(upload)
+		s.Nil(err)
+		uploadMgr.SetMode(UploadModeStreaming)
+
+		err = uploadMgr.Upload()
+		s.Nil(err)
+		s.True(source.failed)
+		s.Equal(content, s.uploadedContent(uploadMgr.url))
+	})
+}
+
+func (s *UploadTestSuite) TestAdaptiveUpload() {
+	content := bytes.Repeat([]byte("0123456789"), 1024*1024)
+
+	cfg := DefaultConfig()
+	cfg.UploadMode = UploadModeAdaptive
+	cfg.ChunkSizeBytes = 64 * 1024
+	cfg.MinChunkSizeBytes = 64 * 1024
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	fingerprint := "fingerprint-TestAdaptiveUpload"
+	upload, err := NewUploadFromBytes(content, &fingerprint)
+	s.Nil(err)
+
+	uploadMgr, err := client.CreateUpload(upload)
+	s.Nil(err)
+
+	err = uploadMgr.Upload()
+	s.Nil(err)
+	s.Equal(content, s.uploadedContent(uploadMgr.url))
+}
+
+func (s *UploadTestSuite) TestUploadQueue() {
+	client, err := NewClient(s.url, nil)
+	s.Nil(err)
+
+	queue, err := NewUploadQueue(client, 3)
+	s.Nil(err)
+
+	const uploads = 10
+	for i := 0; i < uploads; i++ {
+		fingerprint := "fingerprint-TestUploadQueue-" + strconv.Itoa(i)
+		upload, err := NewUploadFromBytes(bytes.Repeat([]byte{byte(i)}, 1024*1024), &fingerprint)
+		s.Nil(err)
+
+		_, err = queue.Add(upload, i%3)
+		s.Nil(err)
+	}
+
+	failed, err := queue.AddFunc(func() (*Upload, error) {
+		return nil, os.ErrNotExist
+	}, 0)
+	s.Nil(err)
+
+	summary := queue.Wait()
+	s.Len(summary.Succeeded, uploads)
+	s.Len(summary.Failed, 1)
+	s.Equal(failed, summary.Failed[0])
+	s.ErrorIs(failed.Err(), os.ErrNotExist)
+	s.EqualValues(uploads*1024*1024, summary.BytesUploaded)
+
+	for _, item := range summary.Succeeded {
+		s.NotEmpty(item.URL())
+		s.Equal(item.Size(), item.Offset())
+	}
+
+	progress := queue.Progress()
+	s.Equal(uploads, progress.Succeeded)
+	s.Equal(progress.BytesTotal, progress.BytesUploaded)
+
+	_, err = queue.Add(&Upload{}, 0)
+	s.ErrorIs(err, ErrQueueClosed)
+}
+
+func (s *UploadTestSuite) TestUploadQueueCancel() {
+	client, err := NewClient(s.url, nil)
+	s.Nil(err)
+
+	queue, err := NewUploadQueue(client, 1)
+	s.Nil(err)
+
+	block := make(chan struct{})
+	blocking, err := queue.AddFunc(func() (*Upload, error) {
+		<-block
+		fingerprint := "fingerprint-TestUploadQueueCancel-blocking"
+		return NewUploadFromBytes([]byte("1234567890"), &fingerprint)
+	}, 0)
+	s.Nil(err)
+
+	fingerprint := "fingerprint-TestUploadQueueCancel-pending"
+	upload, err := NewUploadFromBytes([]byte("1234567890"), &fingerprint)
+	s.Nil(err)
+	pending, err := queue.Add(upload, 0)
+	s.Nil(err)
+
+	pending.Cancel()
+	close(block)
+
+	summary := queue.Wait()
+	s.Equal([]*QueueItem{blocking}, summary.Succeeded)
+	s.Equal([]*QueueItem{pending}, summary.Cancelled)
+}
+
+func (s *UploadTestSuite) TestUploadQueueCancelRunning() {
+	cfg := DefaultConfig()
+	cfg.ChunkSizeBytes = 1024
+	cfg.RateLimiter = NewRateLimiter(8 * 1024)
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	queue, err := NewUploadQueue(client, 1)
+	s.Nil(err)
+
+	fingerprint := "fingerprint-TestUploadQueueCancelRunning"
+	upload, err := NewUploadFromBytes(make([]byte, 64*1024), &fingerprint)
+	s.Nil(err)
+	item, err := queue.Add(upload, 0)
+	s.Nil(err)
+
+	// cancel while chunks are being sent and progress published
+	for item.Offset() < 10*1024 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	item.Cancel()
+
+	summary := queue.Wait()
+	s.Equal([]*QueueItem{item}, summary.Cancelled)
+	s.Less(item.Offset(), item.Size())
+}
+
+func (s *UploadTestSuite) TestOfflineQueue() {
+	dir := s.T().TempDir()
+	entriesPath := filepath.Join(dir, "queue.json")
+
+	path := filepath.Join(dir, "file")
+	s.Nil(os.WriteFile(path, []byte("1234567890"), 0o600))
+
+	// unreachable server
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	entries, err := NewFileStore(entriesPath)
+	s.Nil(err)
+	offline, err := NewOfflineQueue(unreachable.URL, nil, entries, 2)
+	s.Nil(err)
+
+	entry, err := offline.Enqueue(path, Metadata{"source": "TestOfflineQueue"}, nil, 0)
+	s.Nil(err)
+	s.NotEmpty(entry.Fingerprint)
+
+	_, err = offline.Drain(context.Background())
+	s.NotNil(err)
+	s.Len(offline.Pending(), 1)
+
+	// restarted process, now online
+	entries, err = NewFileStore(entriesPath)
+	s.Nil(err)
+	cfg := DefaultConfig()
+	cfg.Store, err = NewFileStore(filepath.Join(dir, "uploads.json"))
+	s.Nil(err)
+	offline, err = NewOfflineQueue(s.url, cfg, entries, 2)
+	s.Nil(err)
+	s.Len(offline.Pending(), 1)
+
+	summary, err := offline.Drain(context.Background())
+	s.Nil(err)
+	s.Len(summary.Succeeded, 1)
+	s.Empty(offline.Pending())
+	s.Equal([]byte("1234567890"), s.uploadedContent(summary.Succeeded[0].URL()))
+
+	url, ok := cfg.Store.Get(entry.Fingerprint)
+	s.True(ok)
+	s.Equal(summary.Succeeded[0].URL(), url)
+}
+
+func (s *UploadTestSuite) TestUploadDir() {
+	ctx := context.Background()
+	root := s.T().TempDir()
+	writeTestTree(s.T(), root, "a.txt", "sub/b.txt", "sub/c.csv")
+
+	client, err := NewClient(s.url, nil)
+	s.Nil(err)
+
+	var reports []QueueProgress
+	summary, err := client.UploadDir(ctx, root, &DirOptions{
+		Include:    []string{"*.txt"},
+		Workers:    2,
+		OnProgress: func(p QueueProgress) { reports = append(reports, p) },
+	})
+	s.Nil(err)
+	s.Len(summary.Succeeded, 2)
+	s.Empty(summary.Failed)
+	s.Equal(2, reports[len(reports)-1].Succeeded)
+
+	relativePaths := map[string]bool{}
+	for _, item := range summary.Succeeded {
+		up, err := s.store.GetUpload(ctx, uploadIDFromURL(item.URL()))
+		s.Nil(err)
+		info, err := up.GetInfo(ctx)
+		s.Nil(err)
+
+		relativePaths[info.MetaData["relativePath"]] = true
+		s.Equal([]byte(info.MetaData["relativePath"]), s.uploadedContent(item.URL()))
+	}
+	s.Equal(map[string]bool{"a.txt": true, "sub/b.txt": true}, relativePaths)
+}
+
+func (s *UploadTestSuite) TestTarUpload() {
+	root := s.T().TempDir()
+	writeTestTree(s.T(), root, "a.txt", "sub/b.txt")
+
+	cfg := DefaultConfig()
+	cfg.ChunkSizeBytes = 1024
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	for _, options := range []*TarOptions{{}, {Compression: CompressionGzip, DeferLength: true}} {
+		upload, err := NewTarUpload(root, options, nil)
+		s.Nil(err)
+
+		uploadMgr, err := client.CreateUpload(upload)
+		s.Nil(err)
+
+		err = uploadMgr.Upload()
+		s.Nil(err)
+		s.False(upload.Deferred())
+
+		_, err = upload.stream.Seek(0, io.SeekStart)
+		s.Nil(err)
+		archive, err := io.ReadAll(upload.stream)
+		s.Nil(err)
+		s.Equal(archive, s.uploadedContent(uploadMgr.url))
+	}
+}
+
+func (s *UploadTestSuite) TestCompressedUpload() {
+	content := bytes.Repeat([]byte("0123456789"), 100*1024)
+
+	cfg := DefaultConfig()
+	cfg.ChunkSizeBytes = 1000
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	fingerprint := "fingerprint-TestCompressedUpload"
+	upload, err := NewUploadFromBytes(content, &fingerprint)
+	s.Nil(err)
+
+	compressed, err := NewCompressedUpload(upload, CompressionZstd, &CompressionOptions{SegmentSize: 64 * 1024})
+	s.Nil(err)
+	s.Less(compressed.Size(), upload.Size())
+
+	uploadMgr, err := client.CreateUpload(compressed)
+	s.Nil(err)
+	s.Nil(uploadMgr.Upload())
+
+	reader, err := NewDecompressReader(bytes.NewReader(s.uploadedContent(uploadMgr.url)), compressed.Metadata)
+	s.Nil(err)
+	defer reader.Close()
+	decompressed, err := io.ReadAll(reader)
+	s.Nil(err)
+	s.Equal(content, decompressed)
+}
+
+func (s *UploadTestSuite) TestEncryptedUpload() {
+	content := bytes.Repeat([]byte("0123456789"), 100*1024)
+
+	cfg := DefaultConfig()
+	cfg.ChunkSizeBytes = 100 * 1000
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	fingerprint := "fingerprint-TestEncryptedUpload"
+	upload, err := NewUploadFromBytes(content, &fingerprint)
+	s.Nil(err)
+
+	aead := newTestAEAD(s.T())
+	encrypted, err := NewEncryptedUpload(upload, aead, &EncryptionOptions{KeyID: "key-1"})
+	s.Nil(err)
+
+	uploadMgr, err := client.CreateUpload(encrypted)
+	s.Nil(err)
+	s.Nil(uploadMgr.Upload())
+
+	ciphertext := s.uploadedContent(uploadMgr.url)
+	s.NotContains(string(ciphertext), "0123456789")
+
+	reader, err := NewDecryptReader(bytes.NewReader(ciphertext), aead, encrypted.Metadata)
+	s.Nil(err)
+	plaintext, err := io.ReadAll(reader)
+	s.Nil(err)
+	s.Equal(content, plaintext)
+}
+
+func (s *UploadTestSuite) TestEncryptedUploadResume() {
+	content := bytes.Repeat([]byte("0123456789"), 100*1024)
+
+	cfg := DefaultConfig()
+	cfg.ChunkSizeBytes = 100 * 1000
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+	aead := newTestAEAD(s.T())
+
+	encrypt := func() *Upload {
+		fingerprint := "fingerprint-TestEncryptedUploadResume"
+		upload, err := NewUploadFromBytes(content, &fingerprint)
+		s.Nil(err)
+		encrypted, err := NewEncryptedUpload(upload, aead, &EncryptionOptions{Store: cfg.Store})
+		s.Nil(err)
+		return encrypted
+	}
+
+	// first run sends a single chunk
+	uploadMgr, err := client.CreateOrResumeUpload(encrypt())
+	s.Nil(err)
+	s.Nil(uploadMgr.UploadChunk())
+
+	// a rerun resumes from the server offset rather than starting over
+	encrypted := encrypt()
+	uploadMgr, err = client.CreateOrResumeUpload(encrypted)
+	s.Nil(err)
+	s.EqualValues(cfg.ChunkSizeBytes, uploadMgr.offset)
+	s.Nil(uploadMgr.Upload())
+
+	reader, err := NewDecryptReader(bytes.NewReader(s.uploadedContent(uploadMgr.url)), aead, encrypted.Metadata)
+	s.Nil(err)
+	plaintext, err := io.ReadAll(reader)
+	s.Nil(err)
+	s.Equal(content, plaintext)
+
+	// the nonce record is gone once complete, so is the whole record once terminated
+	s.Equal([]string{encrypted.Fingerprint}, cfg.Store.(ListableStore).Keys())
+
+	uploadMgr, err = client.CreateUpload(encrypt())
+	s.Nil(err)
+	s.Len(cfg.Store.(ListableStore).Keys(), 3)
+	s.Nil(uploadMgr.Terminate(context.Background()))
+	s.Equal([]string{encrypted.Fingerprint}, cfg.Store.(ListableStore).Keys())
+}
+
+func (s *UploadTestSuite) TestCapabilities() {
+	client, err := NewClient(s.url, nil)
+	s.Nil(err)
+
+	capabilities, err := client.Capabilities(context.Background())
+	s.Nil(err)
+	s.Contains(capabilities.Versions, ProtocolVersion)
+	s.Contains(capabilities.Extensions, "creation")
+	s.Contains(capabilities.Extensions, "termination")
+}
+
+func (s *UploadTestSuite) TestTerminateUpload() {
+	ctx := context.Background()
+
+	client, err := NewClient(s.url, nil)
+	s.Nil(err)
+
+	fingerprint := "fingerprint-TestTerminateUpload"
+	upload, err := NewUploadFromBytes([]byte("1234567890"), &fingerprint)
+	s.Nil(err)
+
+	uploadMgr, err := client.CreateUpload(upload)
+	s.Nil(err)
+
+	s.Nil(client.TerminateUpload(ctx, uploadMgr.URL()))
+	s.ErrorIs(client.TerminateUpload(ctx, uploadMgr.URL()), ErrUploadNotFound)
+
+	// resuming finds the upload gone and starts over
+	_, err = client.ResumeUpload(upload)
+	s.ErrorIs(err, ErrUploadNotFound)
+}
+
+func (s *UploadTestSuite) TestGetUploadInfo() {
+	ctx := context.Background()
+
+	cfg := DefaultConfig()
+	cfg.ChunkSizeBytes = 4
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	fingerprint := "fingerprint-TestGetUploadInfo"
+	upload, err := NewUploadFromBytes([]byte("1234567890"), &fingerprint)
+	s.Nil(err)
+	upload.Metadata["filename"] = "digits.txt"
+	upload.Metadata["empty"] = ""
+
+	uploadMgr, err := client.CreateUpload(upload)
+	s.Nil(err)
+	s.Nil(uploadMgr.UploadChunk())
+
+	info, err := client.GetUploadInfo(ctx, uploadMgr.URL())
+	s.Nil(err)
+	s.Equal(uploadMgr.URL(), info.URL)
+	s.EqualValues(4, info.Offset)
+	s.EqualValues(10, info.Length)
+	s.False(info.Deferred())
+	s.False(info.Complete())
+	s.Equal("digits.txt", info.Metadata["filename"])
+	s.Contains(info.Metadata, "empty")
+	s.Equal(UploadConcatNone, info.Concat)
+
+	s.Nil(uploadMgr.Upload())
+	info, err = client.GetUploadInfo(ctx, uploadMgr.URL())
+	s.Nil(err)
+	s.True(info.Complete())
+
+	s.Nil(client.TerminateUpload(ctx, uploadMgr.URL()))
+	_, err = client.GetUploadInfo(ctx, uploadMgr.URL())
+	s.ErrorIs(err, ErrUploadNotFound)
+}
+
+func (s *UploadTestSuite) TestCreateUploadWithoutFingerprint() {
+	cfg := DefaultConfig()
+	store := NewMemoryStore()
+	cfg.Store = store
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	upload, err := NewUploadFromBytes([]byte("1234567890"), nil)
+	s.Nil(err)
+
+	uploadMgr, err := client.CreateUpload(upload)
+	s.Nil(err)
+	s.Nil(uploadMgr.Upload())
+	_, ok := store.Get("")
+	s.False(ok)
+
+	_, err = client.ResumeUpload(upload)
+	s.ErrorIs(err, ErrFingerprintUnset)
+}
+
+func (s *UploadTestSuite) TestCreateUploadMetadata() {
+	cfg := DefaultConfig()
+	cfg.MaxMetadataBytes = 64
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	upload, err := NewUploadFromBytesWithFingerprinter([]byte("1234567890"), &ContentHashFingerprinter{})
+	s.Nil(err)
+
+	upload.Metadata["file name"] = "a.txt"
+	_, err = client.CreateUpload(upload)
+	var metadataErr *MetadataError
+	s.ErrorAs(err, &metadataErr)
+	s.Equal("file name", metadataErr.Key)
+	s.ErrorIs(err, ErrBadMetadata)
+
+	delete(upload.Metadata, "file name")
+	upload.Metadata["filename"] = strings.Repeat("a", 64)
+	_, err = client.CreateUpload(upload)
+	s.ErrorIs(err, ErrMetadataTooLarge)
+
+	upload.Metadata["filename"] = "a.txt"
+	upload.Metadata["empty"] = ""
+	uploadMgr, err := client.CreateUpload(upload)
+	s.Nil(err)
+
+	info, err := client.GetUploadInfo(context.Background(), uploadMgr.URL())
+	s.Nil(err)
+	s.Equal(Metadata{"filename": "a.txt", "empty": ""}, info.Metadata)
+}
+
+func (s *UploadTestSuite) TestMetadataEnrichers() {
+	cfg := DefaultConfig()
+	cfg.MetadataEnrichers = []MetadataEnricher{
+		&FileTypeEnricher{},
+		&SizeEnricher{},
+		MetadataEnricherFunc(func(_upload *Upload) error {
+			_upload.Metadata["client"] = "tusc"
+			return nil
+		}),
+	}
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	fingerprint := "fingerprint-TestMetadataEnrichers"
+	upload, err := NewUploadFromBytes([]byte(`{"a": 1}`), &fingerprint)
+	s.Nil(err)
+	upload.Metadata[MetadataFilename] = "a.json"
+
+	uploadMgr, err := client.CreateUpload(upload)
+	s.Nil(err)
+
+	info, err := client.GetUploadInfo(context.Background(), uploadMgr.URL())
+	s.Nil(err)
+	s.Equal(Metadata{
+		MetadataFilename: "a.json",
+		MetadataFiletype: "application/json",
+		MetadataSize:     "8",
+		"client":         "tusc",
+	}, info.Metadata)
+
+	// enriching doesn't disturb the stream
+	s.Nil(uploadMgr.Upload())
+	s.Equal([]byte(`{"a": 1}`), s.uploadedContent(uploadMgr.URL()))
+}
+
+func (s *UploadTestSuite) TestIntegrity() {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	sum := sha256.Sum256(content)
+
+	for _, mode := range []UploadMode{UploadModeChunked, UploadModeStreaming, UploadModeAdaptive} {
+		cfg := DefaultConfig()
+		cfg.ChunkSizeBytes = 64
+		cfg.UploadMode = mode
+		cfg.Integrity = &IntegrityOptions{DeclareInMetadata: true}
+
+		client, err := NewClient(s.url, cfg)
+		s.Nil(err)
+
+		fingerprint := fmt.Sprintf("fingerprint-TestIntegrity-%d", mode)
+		upload, err := NewUploadFromBytes(content, &fingerprint)
+		s.Nil(err)
+
+		uploadMgr, err := client.CreateUpload(upload)
+		s.Nil(err)
+		s.Nil(uploadMgr.Upload(), mode)
+
+		info, err := client.GetUploadInfo(context.Background(), uploadMgr.URL())
+		s.Nil(err)
+		s.Equal(hex.EncodeToString(sum[:]), info.Metadata[MetadataChecksum])
+		s.Equal("sha256", info.Metadata[MetadataChecksumAlgorithm])
+	}
+
+	cfg := DefaultConfig()
+	cfg.ChunkSizeBytes = 64
+	cfg.Integrity = &IntegrityOptions{}
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	// content sent before a resume is read from the source again
+	fingerprint := "fingerprint-TestIntegrity-resume"
+	upload, err := NewUploadFromBytes(content, &fingerprint)
+	s.Nil(err)
+	upload.Metadata[MetadataChecksum] = hex.EncodeToString(sum[:])
+	upload.Metadata[MetadataChecksumAlgorithm] = "sha256"
+	uploadMgr, err := client.CreateUpload(upload)
+	s.Nil(err)
+	s.Nil(uploadMgr.UploadChunk())
+
+	uploadMgr, err = client.ResumeUpload(upload)
+	s.Nil(err)
+	s.EqualValues(64, uploadMgr.offset)
+	s.Nil(uploadMgr.Upload())
+
+	// a checksum declared for different content
+	fingerprint = "fingerprint-TestIntegrity-mismatch"
+	upload, err = NewUploadFromBytes(content, &fingerprint)
+	s.Nil(err)
+	upload.Metadata[MetadataChecksum] = strings.Repeat("0", 64)
+	upload.Metadata[MetadataChecksumAlgorithm] = "sha256"
+	uploadMgr, err = client.CreateUpload(upload)
+	s.Nil(err)
+
+	err = uploadMgr.Upload()
+	s.ErrorIs(err, ErrChecksumMismatch)
+	var integrityErr *IntegrityError
+	s.ErrorAs(err, &integrityErr)
+	s.Equal(IntegritySourceMetadata, integrityErr.Source)
+	s.Equal(uploadMgr.URL(), integrityErr.URL)
+}
+
+func (s *UploadTestSuite) TestMiddleware() {
+	var mu sync.Mutex
+	var methods []string
+	failed := false
+
+	cfg := DefaultConfig()
+	cfg.ChunkSizeBytes = 4
+	cfg.UploadMode = UploadModeAdaptive
+	cfg.HTTPMethodOverrides = &map[string]string{http.MethodDelete: http.MethodPost}
+	cfg.Middleware = []Middleware{
+		// records what is sent, after method overrides
+		func(_next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(_req *http.Request) (*http.Response, error) {
+				mu.Lock()
+				methods = append(methods, _req.Method+" "+_req.Header.Get("X-HTTP-Method-Override"))
+				mu.Unlock()
+				s.Equal(ProtocolVersion, _req.Header.Get("Tus-Resumable"))
+				return _next.RoundTrip(_req)
+			})
+		},
+		// fails the first PATCH before it reaches the server
+		func(_next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(_req *http.Request) (*http.Response, error) {
+				if _req.Method == http.MethodPatch && !failed {
+					failed = true
+					return &http.Response{
+						StatusCode: http.StatusServiceUnavailable,
+						Body:       io.NopCloser(strings.NewReader("injected")),
+						Request:    _req,
+					}, nil
+				}
+				return _next.RoundTrip(_req)
+			})
+		},
+	}
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	fingerprint := "fingerprint-TestMiddleware"
+	upload, err := NewUploadFromBytes([]byte("1234567890"), &fingerprint)
+	s.Nil(err)
+
+	uploadMgr, err := client.CreateUpload(upload)
+	s.Nil(err)
+	s.Nil(uploadMgr.Upload())
+	s.Equal([]byte("1234567890"), s.uploadedContent(uploadMgr.URL()))
+
+	s.Nil(client.TerminateUpload(context.Background(), uploadMgr.URL()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	s.Equal("OPTIONS ", methods[0])
+	s.Equal("POST ", methods[1])
+	s.Contains(methods, "HEAD ")
+	s.Contains(methods, "PATCH ")
+	s.Equal("POST DELETE", methods[len(methods)-1])
+}
+
+func (s *UploadTestSuite) TestAuthenticator() {
+	tokens := newTokenServer(s.T(), 3600)
+	target, err := netUrl.Parse(s.ts.URL)
+	s.Nil(err)
+
+	// accepts a token for 2 requests, as if it expired mid upload. A streamed PATCH is the third request.
+	var mu sync.Mutex
+	uses := make(map[string]int)
+	unauthorized := 0
+	proxy := &httputil.ReverseProxy{Rewrite: func(r *httputil.ProxyRequest) {
+		r.SetURL(target)
+		r.SetXForwarded()
+	}}
+	gate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		token := r.Header.Get("Authorization")
+		uses[token]++
+		rejected := !strings.HasPrefix(token, "Bearer token-") || uses[token] > 2
+		if rejected {
+			unauthorized++
+		}
+		mu.Unlock()
+
+		if rejected {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer gate.Close()
+
+	for _, mode := range []UploadMode{UploadModeChunked, UploadModeStreaming} {
+		cfg := DefaultConfig()
+		cfg.ChunkSizeBytes = 2
+		cfg.Retries = -1
+		cfg.UploadMode = mode
+		cfg.Authenticator = &OAuth2Authenticator{TokenURL: tokens.URL, ClientID: "client:id", ClientSecret: "secret"}
+
+		client, err := NewClient(gate.URL+"/uploads/", cfg)
+		s.Nil(err)
+
+		fingerprint := fmt.Sprintf("fingerprint-TestAuthenticator-%d", mode)
+		upload, err := NewUploadFromBytes([]byte("1234567890"), &fingerprint)
+		s.Nil(err)
+
+		uploadMgr, err := client.CreateUpload(upload)
+		s.Nil(err)
+		s.True(strings.HasPrefix(uploadMgr.URL(), gate.URL), uploadMgr.URL())
+		s.Nil(uploadMgr.Upload(), mode)
+		s.Equal([]byte("1234567890"), s.uploadedContent(uploadMgr.URL()))
+	}
+
+	// one token per client, then one per 401
+	mu.Lock()
+	defer mu.Unlock()
+	s.NotZero(unauthorized)
+	s.Len(tokens.requests(), unauthorized+2)
+}
+
+func (s *UploadTestSuite) TestSigner() {
+	target, err := netUrl.Parse(s.ts.URL)
+	s.Nil(err)
+
+	signer := &HMACSigner{Key: []byte("secret"), KeyID: "test"}
+
+	// the gateway rejects anything not signed with the key
+	var mu sync.Mutex
+	verified := make(map[string]int)
+	proxy := &httputil.ReverseProxy{Rewrite: func(r *httputil.ProxyRequest) {
+		r.SetURL(target)
+		r.SetXForwarded()
+	}}
+	gate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := signer.Verify(r, time.Minute); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		verified[r.Method]++
+		mu.Unlock()
+		proxy.ServeHTTP(w, r)
+	}))
+	defer gate.Close()
+
+	cfg := DefaultConfig()
+	cfg.ChunkSizeBytes = 4
+	cfg.Signer = signer
+
+	client, err := NewClient(gate.URL+"/uploads/", cfg)
+	s.Nil(err)
+
+	fingerprint := "fingerprint-TestSigner"
+	upload, err := NewUploadFromBytes([]byte("1234567890"), &fingerprint)
+	s.Nil(err)
+
+	uploadMgr, err := client.CreateUpload(upload)
+	s.Nil(err)
+	s.Nil(uploadMgr.Upload())
+	s.Equal([]byte("1234567890"), s.uploadedContent(uploadMgr.URL()))
+
+	_, err = client.GetUploadInfo(context.Background(), uploadMgr.URL())
+	s.Nil(err)
+
+	mu.Lock()
+	s.Equal(map[string]int{http.MethodOptions: 1, http.MethodPost: 1, http.MethodPatch: 3, http.MethodHead: 1}, verified)
+	mu.Unlock()
+
+	// unsigned requests don't get through
+	cfg = DefaultConfig()
+	unsigned, err := NewClient(gate.URL+"/uploads/", cfg)
+	s.Nil(err)
+	_, err = unsigned.GetUploadInfo(context.Background(), uploadMgr.URL())
+	s.ErrorContains(err, "401")
+}
+
+func (s *UploadTestSuite) TestUploadBatch() {
+	dir := s.T().TempDir()
+	writeTestTree(s.T(), dir, "a.txt", "b.txt")
+
+	cfg := DefaultConfig()
+	cfg.ChunkSizeBytes = 2
+
+	client, err := NewClient(s.url, cfg)
+	s.Nil(err)
+
+	entries := []ManifestEntry{
+		{Path: filepath.Join(dir, "a.txt"), Metadata: Metadata{"team": "ops"}},
+		{Path: filepath.Join(dir, "missing.txt")},
+		{Path: filepath.Join(dir, "b.txt"), Fingerprint: "fingerprint-TestUploadBatch"},
+	}
+
+	report, err := client.UploadBatch(context.Background(), entries, &BatchOptions{Workers: 2})
+	s.Nil(err)
+	s.Equal(2, report.Succeeded)
+	s.Equal(1, report.Failed)
+	s.Len(report.Results, 3)
+
+	for _, i := range []int{0, 2} {
+		result := report.Results[i]
+		content, err := os.ReadFile(result.Path)
+		s.Nil(err)
+
+		s.Equal("succeeded", result.State)
+		s.EqualValues(len(content), result.Size)
+		s.Equal(content, s.uploadedContent(result.URL))
+		s.Equal("sha256", result.ChecksumAlg)
+		s.Equal(fmt.Sprintf("%x", sha256.Sum256(content)), result.Checksum)
+	}
+	s.Equal("fingerprint-TestUploadBatch", report.Results[2].Fingerprint)
+
+	s.Equal("failed", report.Results[1].State)
+	s.Contains(report.Results[1].Error, "missing.txt")
+	s.Empty(report.Results[1].Checksum)
+
+	// a second run resumes the completed uploads
+	again, err := client.UploadBatch(context.Background(), entries, nil)
+	s.Nil(err)
+	s.Equal(report.Results[0].URL, again.Results[0].URL)
+	s.Equal(report.Results[2].URL, again.Results[2].URL)
+}
+
+func (s *UploadTestSuite) TestWatch() {
+	for _, poll := range []bool{false, true} {
+		root := s.T().TempDir()
+		done := filepath.Join(root, "done")
+		state := NewMemoryStore()
+
+		client, err := NewClient(s.url, nil)
+		s.Nil(err)
+
+		events := make(chan WatchEvent, 10)
+		options := &WatchOptions{
+			State:        state,
+			Action:       WatchMove,
+			MoveTo:       done,
+			StableFor:    50 * time.Millisecond,
+			PollInterval: 20 * time.Millisecond,
+			Poll:         poll,
+			OnEvent:      func(e WatchEvent) { events <- e },
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan error)
+		go func() {
+			stopped <- client.Watch(ctx, root, options)
+		}()
+
+		writeTestTree(s.T(), root, "sub/a.txt")
+
+		select {
+		case event := <-events:
+			s.Nil(event.Err)
+			s.False(event.Skipped)
+			s.Equal("sub/a.txt", event.RelativePath)
+			s.Equal([]byte("sub/a.txt"), s.uploadedContent(event.URL))
+		case <-time.After(5 * time.Second):
+			s.FailNow("file not uploaded", "poll %v", poll)
+		}
+
+		_, err = os.Stat(filepath.Join(done, "sub", "a.txt"))
+		s.Nil(err)
+		_, err = os.Stat(filepath.Join(root, "sub", "a.txt"))
+		s.ErrorIs(err, os.ErrNotExist)
+
+		cancel()
+		s.ErrorIs(<-stopped, context.Canceled)
+		s.Len(state.Keys(), 1)
+	}
+}
+
+func (s *UploadTestSuite) TestWatchRestart() {
+	root := s.T().TempDir()
+	writeTestTree(s.T(), root, "a.txt")
+	state := NewMemoryStore()
+
+	client, err := NewClient(s.url, nil)
+	s.Nil(err)
+
+	for _, skipped := range []bool{false, true} {
+		events := make(chan WatchEvent, 10)
+		options := &WatchOptions{
+			State:        state,
+			StableFor:    10 * time.Millisecond,
+			PollInterval: 10 * time.Millisecond,
+			OnEvent:      func(e WatchEvent) { events <- e },
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan error)
+		go func() {
+			stopped <- client.Watch(ctx, root, options)
+		}()
+
+		select {
+		case event := <-events:
+			s.Nil(event.Err)
+			s.Equal(skipped, event.Skipped)
+		case <-time.After(5 * time.Second):
+			s.FailNow("file not handled")
+		}
+
+		cancel()
+		<-stopped
+	}
+}
+
+func (s *UploadTestSuite) TestDownload() {
+	content := bytes.Repeat([]byte("0123456789"), 100*1024)
+
+	client, err := NewClient(s.url, nil)
+	s.Nil(err)
+
+	fingerprint := "fingerprint-TestDownload"
+	upload, err := NewUploadFromBytes(content, &fingerprint)
+	s.Nil(err)
+
+	uploadMgr, err := client.CreateUpload(upload)
+	s.Nil(err)
+
+	file, err := os.Create(filepath.Join(s.T().TempDir(), "download"))
+	s.Nil(err)
+	defer file.Close()
+
+	_, err = client.Download(context.Background(), uploadMgr.URL(), file, nil)
+	s.ErrorIs(err, ErrUploadIncomplete)
+
+	s.Nil(uploadMgr.Upload())
+
+	expected := sha256.Sum256(content)
+	size, err := client.Download(context.Background(), uploadMgr.URL(), file, &DownloadOptions{
+		Workers:          4,
+		PartSize:         100 * 1024,
+		Checksum:         sha256.New,
+		ExpectedChecksum: expected[:],
+	})
+	s.Nil(err)
+	s.EqualValues(len(content), size)
+
+	downloaded, err := os.ReadFile(file.Name())
+	s.Nil(err)
+	s.Equal(content, downloaded)
+}
+
+func (s *UploadTestSuite) uploadedContent(_url string) []byte {
+	ctx := context.Background()
+
+	up, err := s.store.GetUpload(ctx, uploadIDFromURL(_url))
+	s.Nil(err)
+
+	reader, err := up.GetReader(ctx)
+	s.Nil(err)
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	content, err := io.ReadAll(reader)
+	s.Nil(err)
+
+	return content
+}
+
 func TestUploadTestSuite(t *testing.T) {
 	suite.Run(t, new(UploadTestSuite))
 }
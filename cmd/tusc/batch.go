@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/offby0x01/tusc"
+)
+
+func runBatch(_ctx context.Context, _env *env, _args []string) int {
+	var flags clientFlags
+	var format, report, reportChecksum string
+	var workers int
+	var noProgress bool
+	fs := newFlagSet(_env, "batch [flags] MANIFEST",
+		"Uploads the files listed in MANIFEST, or stdin for \"-\", and writes a JSON report of each upload. A manifest is\n"+
+			"JSONL, one {\"path\", \"fingerprint\", \"metadata\", \"priority\"} object per line, or CSV with a header row where\n"+
+			"\"path\" is required, \"fingerprint\" and \"priority\" are optional and other columns are metadata.\n"+
+			"Rerunning a batch resumes interrupted uploads.")
+	flags.register(fs, _env)
+	flags.registerCreate(fs)
+	fs.StringVar(&format, "format", "", "manifest format, jsonl or csv, defaults to csv for .csv files and jsonl otherwise")
+	fs.StringVar(&report, "report", "-", `report file, "-" for stdout`)
+	fs.StringVar(&reportChecksum, "report-checksum", "sha256", "digest of each file recorded in the report: "+strings.Join(sortedNames(checksumAlgorithms), ", "))
+	fs.IntVar(&workers, "workers", 4, "files uploaded concurrently")
+	fs.BoolVar(&noProgress, "no-progress", false, "don't show progress")
+
+	if err := fs.Parse(_args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	manifestFormat := tusc.ManifestFormatFromPath(fs.Arg(0))
+	switch format {
+	case "":
+	case "jsonl":
+		manifestFormat = tusc.ManifestJSONL
+	case "csv":
+		manifestFormat = tusc.ManifestCSV
+	default:
+		fmt.Fprintf(_env.stderr, "tusc: unknown manifest format %q\n", format)
+		return exitUsage
+	}
+
+	newHash, ok := checksumAlgorithms[reportChecksum]
+	if !ok {
+		fmt.Fprintf(_env.stderr, "tusc: unsupported checksum algorithm %q\n", reportChecksum)
+		return exitUsage
+	}
+
+	cfg, store, err := flags.setup(true)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitUsage
+	}
+	defer store.Close()
+
+	entries, err := readManifestArg(_env, fs.Arg(0), manifestFormat)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitFailure
+	}
+
+	// scope fingerprints to the endpoint, as "tusc upload" does
+	for i := range entries {
+		if entries[i].Fingerprint != "" {
+			entries[i].Fingerprint = endpointFingerprint(flags.endpoint, entries[i].Fingerprint)
+		}
+	}
+
+	client, err := tusc.NewClient(flags.endpoint, cfg)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitFailure
+	}
+
+	options := &tusc.BatchOptions{
+		Workers:       workers,
+		Fingerprinter: endpointFingerprinter(flags.endpoint),
+		Checksum:      newHash,
+		ChecksumAlg:   reportChecksum,
+	}
+	if !noProgress && isTerminal(_env.stderr) {
+		options.OnProgress = func(_progress tusc.QueueProgress) {
+			fmt.Fprintf(_env.stderr, "\r%d/%d done, %d failed, %s uploaded ", _progress.Succeeded+_progress.Failed+_progress.Cancelled,
+				len(entries), _progress.Failed, formatByteSize(_progress.BytesUploaded))
+		}
+		options.ProgressInterval = 500 * time.Millisecond
+	}
+
+	result, err := client.UploadBatch(_ctx, entries, options)
+	if options.OnProgress != nil {
+		fmt.Fprintln(_env.stderr)
+	}
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitFailure
+	}
+
+	if err = writeReport(_env, report, result); err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitFailure
+	}
+
+	fmt.Fprintf(_env.stderr, "%d succeeded, %d failed, %d cancelled, %s uploaded in %s\n", result.Succeeded, result.Failed,
+		result.Cancelled, formatByteSize(result.BytesUploaded), result.Duration.Round(time.Millisecond))
+
+	switch {
+	case _ctx.Err() != nil:
+		return exitInterrupted
+	case result.Failed != 0 || result.Cancelled != 0:
+		return exitFailure
+	default:
+		return exitOK
+	}
+}
+
+func readManifestArg(_env *env, _path string, _format tusc.ManifestFormat) ([]tusc.ManifestEntry, error) {
+	if _path == "-" {
+		return tusc.ReadManifest(_env.stdin, _format)
+	}
+
+	file, err := os.Open(_path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return tusc.ReadManifest(file, _format)
+}
+
+func writeReport(_env *env, _path string, _report *tusc.BatchReport) error {
+	if _path == "-" {
+		return encodeReport(_env.stdout, _report)
+	}
+
+	file, err := os.Create(_path)
+	if err != nil {
+		return err
+	}
+	if err = encodeReport(file, _report); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func encodeReport(_w io.Writer, _report *tusc.BatchReport) error {
+	encoder := json.NewEncoder(_w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(_report)
+}
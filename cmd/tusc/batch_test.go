@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/offby0x01/tusc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	server := newTestServer(t)
+	dir := t.TempDir()
+	store := filepath.Join(dir, "store.json")
+	reportPath := filepath.Join(dir, "report.json")
+	vars := map[string]string{endpointEnv: server.url}
+
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	assert.Nil(t, os.WriteFile(a, []byte("aaaa"), 0o600))
+	assert.Nil(t, os.WriteFile(b, []byte("bbbbbb"), 0o600))
+
+	manifest := filepath.Join(dir, "manifest.csv")
+	assert.Nil(t, os.WriteFile(manifest, []byte(fmt.Sprintf("path,fingerprint,team\n%s,,ops\n%s,fp-b,\n", a, b)), 0o600))
+
+	e := newTestEnv(nil, vars)
+	code := run(ctx, &e.env, []string{"batch", "-store", store, "-report", reportPath, "-chunk-size", "2", manifest})
+	assert.Equal(t, exitOK, code, e.stderr.String())
+	assert.Contains(t, e.stderr.String(), "2 succeeded, 0 failed")
+
+	report := readReport(t, reportPath)
+	assert.Equal(t, 2, report.Succeeded)
+	assert.Equal(t, []byte("aaaa"), server.content(t, report.Results[0].URL))
+	assert.Equal(t, []byte("bbbbbb"), server.content(t, report.Results[1].URL))
+	assert.Equal(t, endpointFingerprint(server.url, "fp-b"), report.Results[1].Fingerprint)
+
+	// info resolves the manifest fingerprint like one given to upload
+	e = newTestEnv(nil, vars)
+	assert.Equal(t, exitOK, run(ctx, &e.env, []string{"info", "-store", store, "fp-b"}), e.stderr.String())
+	assert.Contains(t, e.stdout.String(), report.Results[1].URL)
+
+	// JSONL on stdin with a missing file, the report goes to stdout
+	e = newTestEnv([]byte(fmt.Sprintf("{\"path\": %q}\n{\"path\": %q}\n", a, filepath.Join(dir, "missing"))), vars)
+	code = run(ctx, &e.env, []string{"batch", "-store", store, "-report-checksum", "md5", "-"})
+	assert.Equal(t, exitFailure, code)
+
+	var stdoutReport tusc.BatchReport
+	assert.Nil(t, json.Unmarshal(e.stdout.Bytes(), &stdoutReport))
+	assert.Equal(t, report.Results[0].URL, stdoutReport.Results[0].URL)
+	assert.Equal(t, "md5", stdoutReport.Results[0].ChecksumAlg)
+	assert.Equal(t, "failed", stdoutReport.Results[1].State)
+
+	e = newTestEnv(nil, vars)
+	assert.Equal(t, exitUsage, run(ctx, &e.env, []string{"batch", "-store", store, "-format", "xml", manifest}))
+}
+
+func readReport(t *testing.T, _path string) tusc.BatchReport {
+	var report tusc.BatchReport
+	assert.Nil(t, json.Unmarshal(mustReadFile(t, _path), &report))
+	return report
+}
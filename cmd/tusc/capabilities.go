@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/offby0x01/tusc"
+)
+
+func runCapabilities(_ctx context.Context, _env *env, _args []string) int {
+	var flags clientFlags
+	var asJSON bool
+	fs := newFlagSet(_env, "capabilities [flags] [ENDPOINT]",
+		"Shows the protocol versions, extensions, max upload size and checksum algorithms a server reports for OPTIONS.")
+	flags.register(fs, _env)
+	fs.BoolVar(&asJSON, "json", false, "print JSON")
+
+	if err := fs.Parse(_args); err != nil {
+		return exitUsage
+	}
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		flags.endpoint = fs.Arg(0)
+	default:
+		fs.Usage()
+		return exitUsage
+	}
+
+	cfg, store, err := flags.setup(true)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitUsage
+	}
+	defer store.Close()
+
+	// NewClient rejects servers advertising extensions it does not know, which is exactly what should be shown here
+	client := &tusc.Client{Config: cfg, BaseUrl: flags.endpoint, Version: tusc.ProtocolVersion}
+
+	capabilities, err := client.Capabilities(_ctx)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitFailure
+	}
+
+	if asJSON {
+		return writeJSON(_env, capabilities)
+	}
+
+	maxSize := "unlimited"
+	if capabilities.MaxSize > 0 {
+		maxSize = fmt.Sprintf("%d (%s)", capabilities.MaxSize, formatByteSize(capabilities.MaxSize))
+	}
+
+	fmt.Fprintf(_env.stdout, "endpoint:   %s\n", flags.endpoint)
+	fmt.Fprintf(_env.stdout, "versions:   %s\n", strings.Join(capabilities.Versions, ", "))
+	fmt.Fprintf(_env.stdout, "extensions: %s\n", strings.Join(capabilities.Extensions, ", "))
+	fmt.Fprintf(_env.stdout, "max size:   %s\n", maxSize)
+	if len(capabilities.ChecksumAlgorithms) != 0 {
+		fmt.Fprintf(_env.stdout, "checksums:  %s\n", strings.Join(capabilities.ChecksumAlgorithms, ", "))
+	}
+
+	return exitOK
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/offby0x01/tusc"
+)
+
+const (
+	endpointEnv          = "TUSC_ENDPOINT"
+	oauthTokenURLEnv     = "TUSC_OAUTH_TOKEN_URL"
+	oauthClientIDEnv     = "TUSC_OAUTH_CLIENT_ID"
+	oauthClientSecretEnv = "TUSC_OAUTH_CLIENT_SECRET"
+	signingKeyEnv        = "TUSC_SIGNING_KEY"
+)
+
+// checksumAlgorithms names as advertised in Tus-Checksum-Algorithm
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// metadataEnrichers names accepted by -enrich
+var metadataEnrichers = map[string]func() tusc.MetadataEnricher{
+	"filetype": func() tusc.MetadataEnricher { return &tusc.FileTypeEnricher{} },
+	"size":     func() tusc.MetadataEnricher { return &tusc.SizeEnricher{} },
+	"modtime":  func() tusc.MetadataEnricher { return &tusc.ModTimeEnricher{} },
+	"checksum": func() tusc.MetadataEnricher { return &tusc.ChecksumEnricher{} },
+}
+
+// clientFlags are shared by every command talking to a server
+type clientFlags struct {
+	endpoint  string
+	headers   headerFlag
+	overrides keyValueFlag
+	store     string
+	chunkSize byteSizeFlag
+	checksum  string
+	enrich    enrichFlag
+	verify    bool
+
+	oauthTokenURL     string
+	oauthClientID     string
+	oauthClientSecret string
+	oauthScopes       stringsFlag
+
+	signingKey    string
+	signingKeyID  string
+	signedHeaders stringsFlag
+}
+
+func (f *clientFlags) register(_fs *flag.FlagSet, _env *env) {
+	f.chunkSize = byteSizeFlag(tusc.DefaultConfig().ChunkSizeBytes)
+
+	_fs.StringVar(&f.endpoint, "endpoint", _env.getenv(endpointEnv), "tus upload endpoint, defaults to $"+endpointEnv)
+	_fs.Var(&f.headers, "H", `extra request header "Name: value", repeatable`)
+	_fs.Var(&f.overrides, "method-override", "send METHOD=OVERRIDE e.g. PATCH=POST with X-HTTP-Method-Override, repeatable")
+	_fs.StringVar(&f.store, "store", defaultStorePath(), "file recording upload URLs by fingerprint, used to resume")
+	_fs.Var(&f.chunkSize, "chunk-size", "bytes per PATCH request, accepts suffixes like KiB, MiB, GiB")
+	_fs.StringVar(&f.checksum, "checksum", "", "checksum algorithm sent with each chunk: "+strings.Join(sortedNames(checksumAlgorithms), ", "))
+	_fs.StringVar(&f.oauthTokenURL, "oauth-token-url", _env.getenv(oauthTokenURLEnv), "authorize with OAuth 2.0 client credentials from this token endpoint, renewing tokens as they expire. Defaults to $"+oauthTokenURLEnv+", the secret is read from $"+oauthClientSecretEnv)
+	_fs.StringVar(&f.oauthClientID, "oauth-client-id", _env.getenv(oauthClientIDEnv), "OAuth 2.0 client ID, defaults to $"+oauthClientIDEnv)
+	_fs.Var(&f.oauthScopes, "oauth-scope", "OAuth 2.0 scope requested, repeatable")
+	f.oauthClientSecret = _env.getenv(oauthClientSecretEnv)
+	_fs.StringVar(&f.signingKeyID, "signing-key-id", "", "key ID sent with HMAC-SHA256 request signatures, requests are signed when $"+signingKeyEnv+" is set")
+	_fs.Var(&f.signedHeaders, "signed-header", "header covered by request signatures, repeatable, defaults to "+strings.Join(tusc.DefaultSignedHeaders, " and "))
+	f.signingKey = _env.getenv(signingKeyEnv)
+}
+
+// registerCreate adds -enrich and -verify, only registered by commands creating uploads
+func (f *clientFlags) registerCreate(_fs *flag.FlagSet) {
+	_fs.Var(&f.enrich, "enrich", "metadata added to new uploads, comma separated: "+strings.Join(sortedNames(metadataEnrichers), ", "))
+	_fs.BoolVar(&f.verify, "verify", false, "declare a sha256 of each file in checksum metadata and check the uploaded content matches it")
+}
+
+func (f *clientFlags) openStore() (tusc.ListableStore, error) {
+	store, err := tusc.NewFileStore(f.store)
+	if err != nil {
+		return nil, fmt.Errorf("store %s: %w", f.store, err)
+	}
+	return store, nil
+}
+
+func (f *clientFlags) config(_store tusc.Store) (*tusc.Config, error) {
+	cfg := tusc.DefaultConfig()
+	cfg.ChunkSizeBytes = int64(f.chunkSize)
+	cfg.Header = http.Header(f.headers)
+	cfg.Store = _store
+
+	if len(f.overrides) != 0 {
+		overrides := map[string]string(f.overrides)
+		cfg.HTTPMethodOverrides = &overrides
+	}
+
+	if f.checksum != "" {
+		newHash, ok := checksumAlgorithms[f.checksum]
+		if !ok {
+			return nil, fmt.Errorf("unsupported checksum algorithm %q", f.checksum)
+		}
+		cfg.ChecksumAlg = f.checksum
+		cfg.ChecksumFunc = newHash
+	}
+
+	if f.oauthTokenURL != "" {
+		cfg.Authenticator = &tusc.OAuth2Authenticator{
+			TokenURL:     f.oauthTokenURL,
+			ClientID:     f.oauthClientID,
+			ClientSecret: f.oauthClientSecret,
+			Scopes:       f.oauthScopes,
+		}
+	}
+
+	if f.signingKey != "" {
+		cfg.Signer = &tusc.HMACSigner{
+			Key:     []byte(f.signingKey),
+			KeyID:   f.signingKeyID,
+			Headers: f.signedHeaders,
+		}
+	}
+
+	if f.verify {
+		cfg.Integrity = &tusc.IntegrityOptions{DeclareInMetadata: true}
+	}
+
+	for _, name := range f.enrich {
+		cfg.MetadataEnrichers = append(cfg.MetadataEnrichers, metadataEnrichers[name]())
+	}
+
+	if err := cfg.ValidateAndSetDefaults(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// setup opens the store and builds the config, any error is a usage error
+func (f *clientFlags) setup(_requireEndpoint bool) (*tusc.Config, tusc.ListableStore, error) {
+	if _requireEndpoint && f.endpoint == "" {
+		return nil, nil, fmt.Errorf("no endpoint, set -endpoint or $%s", endpointEnv)
+	}
+
+	store, err := f.openStore()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := f.config(store)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, store, nil
+}
+
+func newFlagSet(_env *env, _usage string, _description string) *flag.FlagSet {
+	name, _, _ := strings.Cut(_usage, " ")
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(_env.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: tusc %s\n\n%s\n\n", _usage, _description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func defaultStorePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "tusc", "uploads.json")
+}
+
+func sortedNames[V any](_m map[string]V) []string {
+	names := make([]string, 0, len(_m))
+	for name := range _m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// headerFlag collects "Name: value" pairs
+type headerFlag http.Header
+
+func (h *headerFlag) String() string {
+	return fmt.Sprint(map[string][]string(*h))
+}
+
+func (h *headerFlag) Set(_value string) error {
+	name, value, ok := strings.Cut(_value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return errors.New(`expected "Name: value"`)
+	}
+	if *h == nil {
+		*h = make(headerFlag)
+	}
+	http.Header(*h).Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	return nil
+}
+
+// stringsFlag collects repeated values
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(_value string) error {
+	*s = append(*s, _value)
+	return nil
+}
+
+// enrichFlag collects metadata enricher names, comma separated or repeated
+type enrichFlag []string
+
+func (e *enrichFlag) String() string {
+	return strings.Join(*e, ",")
+}
+
+func (e *enrichFlag) Set(_value string) error {
+	for _, name := range strings.Split(_value, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := metadataEnrichers[name]; !ok {
+			return fmt.Errorf("unknown enricher %q", name)
+		}
+		if !slices.Contains(*e, name) {
+			*e = append(*e, name)
+		}
+	}
+	return nil
+}
+
+// keyValueFlag collects key=value pairs
+type keyValueFlag map[string]string
+
+func (kv *keyValueFlag) String() string {
+	return fmt.Sprint(map[string]string(*kv))
+}
+
+func (kv *keyValueFlag) Set(_value string) error {
+	key, value, ok := strings.Cut(_value, "=")
+	if !ok || key == "" {
+		return errors.New("expected key=value")
+	}
+	if *kv == nil {
+		*kv = make(keyValueFlag)
+	}
+	(*kv)[key] = value
+	return nil
+}
+
+// byteSizeFlag a byte count with an optional binary or decimal unit suffix
+type byteSizeFlag int64
+
+var byteSizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	// longest suffixes first so "MiB" isn't taken as "B"
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+func (b *byteSizeFlag) String() string {
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSizeFlag) Set(_value string) error {
+	size, err := parseByteSize(_value)
+	if err != nil {
+		return err
+	}
+	*b = byteSizeFlag(size)
+	return nil
+}
+
+func parseByteSize(_s string) (int64, error) {
+	s := strings.TrimSpace(_s)
+	multiplier := int64(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(strings.ToUpper(s), strings.ToUpper(unit.suffix)) {
+			s = strings.TrimSpace(s[:len(s)-len(unit.suffix)])
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", _s)
+	}
+	return n * multiplier, nil
+}
+
+// formatByteSize renders _n with a binary unit, e.g. 1.5 MiB
+func formatByteSize(_n int64) string {
+	const unit = 1024
+	if _n < unit {
+		return fmt.Sprintf("%d B", _n)
+	}
+	div, exp := int64(unit), 0
+	for n := _n / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(_n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/offby0x01/tusc"
+)
+
+// uploadInfo the JSON output of "tusc info"
+type uploadInfo struct {
+	URL          string            `json:"url"`
+	Fingerprints []string          `json:"fingerprints,omitempty"`
+	Offset       int64             `json:"offset"`
+	Length       int64             `json:"length"`
+	Deferred     bool              `json:"deferred,omitempty"`
+	Metadata     tusc.Metadata     `json:"metadata,omitempty"`
+	Concat       tusc.UploadConcat `json:"concat,omitempty"`
+	ConcatParts  []string          `json:"concatParts,omitempty"`
+	Expires      *time.Time        `json:"expires,omitempty"`
+}
+
+func newUploadInfo(_info *tusc.UploadInfo, _fingerprints []string) *uploadInfo {
+	info := &uploadInfo{
+		URL:          _info.URL,
+		Fingerprints: _fingerprints,
+		Offset:       _info.Offset,
+		Length:       _info.Length,
+		Deferred:     _info.Deferred(),
+		Metadata:     _info.Metadata,
+		Concat:       _info.Concat,
+		ConcatParts:  _info.ConcatParts,
+	}
+	if !_info.Expires.IsZero() {
+		info.Expires = &_info.Expires
+	}
+	return info
+}
+
+func runInfo(_ctx context.Context, _env *env, _args []string) int {
+	var flags clientFlags
+	var asJSON bool
+	fs := newFlagSet(_env, "info [flags] URL|FINGERPRINT",
+		"Shows the offset, length, metadata and expiry of an upload, given its URL or the fingerprint it was uploaded with.")
+	flags.register(fs, _env)
+	fs.BoolVar(&asJSON, "json", false, "print JSON")
+
+	if err := fs.Parse(_args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	s, err := newSession(&flags)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitUsage
+	}
+	defer s.close()
+
+	url, fingerprints, err := s.resolve(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitFailure
+	}
+
+	client, err := s.client(url)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitFailure
+	}
+
+	upload, err := client.GetUploadInfo(_ctx, url)
+	if err != nil {
+		fmt.Fprintf(_env.stderr, "tusc: %s: %v\n", url, err)
+		return exitFailure
+	}
+	info := newUploadInfo(upload, fingerprints)
+
+	if asJSON {
+		return writeJSON(_env, info)
+	}
+
+	fmt.Fprintf(_env.stdout, "url:      %s\n", info.URL)
+	for _, fingerprint := range info.Fingerprints {
+		fmt.Fprintf(_env.stdout, "stored:   %s\n", fingerprint)
+	}
+	if info.Deferred {
+		fmt.Fprintf(_env.stdout, "offset:   %d (length deferred)\n", info.Offset)
+	} else {
+		fmt.Fprintf(_env.stdout, "offset:   %d of %d (%s)\n", info.Offset, info.Length, percent(info.Offset, info.Length))
+	}
+	if info.Concat != tusc.UploadConcatNone {
+		fmt.Fprintf(_env.stdout, "concat:   %s\n", strings.Join(append([]string{string(info.Concat)}, info.ConcatParts...), " "))
+	}
+	if info.Expires != nil {
+		fmt.Fprintf(_env.stdout, "expires:  %s\n", info.Expires.Format(time.RFC3339))
+	}
+	if len(info.Metadata) != 0 {
+		fmt.Fprintln(_env.stdout, "metadata:")
+		keys := make([]string, 0, len(info.Metadata))
+		for k := range info.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(_env.stdout, "  %s: %s\n", k, info.Metadata[k])
+		}
+	}
+
+	return exitOK
+}
+
+func percent(_offset int64, _length int64) string {
+	if _length <= 0 {
+		return "100%"
+	}
+	return strconv.FormatInt(_offset*100/_length, 10) + "%"
+}
+
+func writeJSON(_env *env, _v any) int {
+	encoder := json.NewEncoder(_env.stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(_v); err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitFailure
+	}
+	return exitOK
+}
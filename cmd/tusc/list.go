@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/offby0x01/tusc"
+)
+
+const (
+	statusComplete = "complete"
+	statusPartial  = "partial"
+	statusMissing  = "missing"
+	statusError    = "error"
+)
+
+// storeEntry a store entry and the state of its upload
+type storeEntry struct {
+	Fingerprint string `json:"fingerprint"`
+	URL         string `json:"url"`
+	Status      string `json:"status"`
+	Offset      int64  `json:"offset,omitempty"`
+	Length      int64  `json:"length,omitempty"`
+	Deferred    bool   `json:"deferred,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// prunable entries can never be resumed, or there's nothing left to resume
+func (e *storeEntry) prunable() bool {
+	return e.Status == statusComplete || e.Status == statusMissing
+}
+
+func runList(_ctx context.Context, _env *env, _args []string) int {
+	var flags clientFlags
+	var asJSON bool
+	fs := newFlagSet(_env, "list [flags]",
+		"Lists the uploads recorded in the store with their status on the server: complete, partial, missing or error.")
+	flags.register(fs, _env)
+	fs.BoolVar(&asJSON, "json", false, "print JSON")
+
+	if err := fs.Parse(_args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	s, err := newSession(&flags)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitUsage
+	}
+	defer s.close()
+
+	entries := s.entries(_ctx)
+	if asJSON {
+		return writeJSON(_env, entries)
+	}
+
+	w := tabwriter.NewWriter(_env.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FINGERPRINT\tSTATUS\tPROGRESS\tURL")
+	for _, entry := range entries {
+		progress := ""
+		switch {
+		case entry.Status == statusError:
+			progress = entry.Error
+		case entry.Deferred:
+			progress = formatByteSize(entry.Offset)
+		case entry.Status != statusMissing:
+			progress = fmt.Sprintf("%s of %s (%s)", formatByteSize(entry.Offset), formatByteSize(entry.Length), percent(entry.Offset, entry.Length))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.Fingerprint, entry.Status, progress, entry.URL)
+	}
+	w.Flush()
+
+	return exitOK
+}
+
+func runPrune(_ctx context.Context, _env *env, _args []string) int {
+	var flags clientFlags
+	var asJSON, dryRun bool
+	fs := newFlagSet(_env, "prune [flags]",
+		"Removes store entries for uploads that are complete or no longer exist on the server. Uploads are left untouched.")
+	flags.register(fs, _env)
+	fs.BoolVar(&asJSON, "json", false, "print JSON")
+	fs.BoolVar(&dryRun, "dry-run", false, "only list what would be removed")
+
+	if err := fs.Parse(_args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	s, err := newSession(&flags)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitUsage
+	}
+	defer s.close()
+
+	pruned := []storeEntry{}
+	for _, entry := range s.entries(_ctx) {
+		if !entry.prunable() {
+			continue
+		}
+		if !dryRun {
+			s.store.Delete(entry.Fingerprint)
+		}
+		pruned = append(pruned, entry)
+	}
+
+	if asJSON {
+		return writeJSON(_env, pruned)
+	}
+
+	verb := "pruned"
+	if dryRun {
+		verb = "would prune"
+	}
+	for _, entry := range pruned {
+		fmt.Fprintf(_env.stdout, "%s %s (%s) %s\n", verb, entry.Fingerprint, entry.Status, entry.URL)
+	}
+
+	return exitOK
+}
+
+// entries of the store in fingerprint order, with the status of each upload. Records other than upload URLs are
+// skipped, see tusc.IsUploadKey.
+func (s *session) entries(_ctx context.Context) []storeEntry {
+	var entries []storeEntry
+	for _, fingerprint := range s.store.Keys() {
+		if !tusc.IsUploadKey(fingerprint) {
+			continue
+		}
+
+		url, ok := s.store.Get(fingerprint)
+		if !ok {
+			continue
+		}
+
+		entry := storeEntry{Fingerprint: fingerprint, URL: url}
+		info, err := s.info(_ctx, url)
+		switch {
+		case errors.Is(err, tusc.ErrUploadNotFound):
+			entry.Status = statusMissing
+		case err != nil:
+			entry.Status = statusError
+			entry.Error = err.Error()
+		default:
+			entry.Offset, entry.Length, entry.Deferred = info.Offset, info.Length, info.Deferred()
+			entry.Status = statusPartial
+			if info.Complete() {
+				entry.Status = statusComplete
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (s *session) info(_ctx context.Context, _url string) (*tusc.UploadInfo, error) {
+	client, err := s.client(_url)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetUploadInfo(_ctx, _url)
+}
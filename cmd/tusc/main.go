@@ -0,0 +1,89 @@
+// Command tusc uploads files to a tus server.
+//
+//	tusc upload -endpoint https://example.com/files/ [flags] FILE...
+//	tusc list
+//
+// Run "tusc help" for the full list of commands and flags.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitUsage       = 2
+	exitInterrupted = 130
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(_ctx context.Context, _env *env, _args []string) int
+}
+
+// env is the process environment of a command, swapped out in tests
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+}
+
+var commands = []command{
+	{name: "upload", summary: "upload files or stdin, resuming earlier attempts", run: runUpload},
+	{name: "batch", summary: "upload the files listed in a manifest and report the results", run: runBatch},
+	{name: "watch", summary: "upload files appearing in a directory until interrupted", run: runWatch},
+	{name: "info", summary: "show the state and metadata of an upload", run: runInfo},
+	{name: "list", summary: "list stored uploads with their status", run: runList},
+	{name: "terminate", summary: "delete uploads from the server", run: runTerminate},
+	{name: "prune", summary: "remove store entries of complete or missing uploads", run: runPrune},
+	{name: "capabilities", summary: "show what the server supports", run: runCapabilities},
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, &env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv}, os.Args[1:])
+	stop()
+	os.Exit(code)
+}
+
+func run(_ctx context.Context, _env *env, _args []string) int {
+	if len(_args) == 0 {
+		usage(_env.stderr)
+		return exitUsage
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == _args[0] {
+			return cmd.run(_ctx, _env, _args[1:])
+		}
+	}
+
+	switch _args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(_env.stdout)
+		return exitOK
+	}
+
+	fmt.Fprintf(_env.stderr, "tusc: unknown command %q\n\n", _args[0])
+	usage(_env.stderr)
+	return exitUsage
+}
+
+func usage(_w io.Writer) {
+	fmt.Fprintln(_w, "usage: tusc <command> [flags] [args]")
+	fmt.Fprintln(_w)
+	fmt.Fprintln(_w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(_w, "  %-14s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(_w)
+	fmt.Fprintln(_w, `run "tusc <command> -h" for the flags of a command`)
+}
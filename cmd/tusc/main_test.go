@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/offby0x01/tusc"
+	"github.com/stretchr/testify/assert"
+	"github.com/tus/tusd/pkg/filestore"
+	tusd "github.com/tus/tusd/pkg/handler"
+)
+
+type testServer struct {
+	url string
+	dir string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	dir := t.TempDir()
+	composer := tusd.NewStoreComposer()
+	filestore.New(dir).UseIn(composer)
+
+	handler, err := tusd.NewHandler(tusd.Config{
+		BasePath:      "/uploads/",
+		StoreComposer: composer,
+	})
+	assert.Nil(t, err)
+
+	ts := httptest.NewServer(http.StripPrefix("/uploads/", handler))
+	t.Cleanup(ts.Close)
+
+	return &testServer{url: ts.URL + "/uploads/", dir: dir}
+}
+
+// content of the upload at _url as stored by the server
+func (s *testServer) content(t *testing.T, _url string) []byte {
+	content, err := os.ReadFile(filepath.Join(s.dir, filepath.Base(_url)))
+	assert.Nil(t, err)
+	return content
+}
+
+type testEnv struct {
+	env
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+}
+
+func newTestEnv(_stdin []byte, _vars map[string]string) *testEnv {
+	e := &testEnv{}
+	e.env = env{
+		stdin:  bytes.NewReader(_stdin),
+		stdout: &e.stdout,
+		stderr: &e.stderr,
+		getenv: func(key string) string { return _vars[key] },
+	}
+	return e
+}
+
+// uploadedURLs maps each path to the URL printed by "tusc upload"
+func uploadedURLs(_stdout string) map[string]string {
+	urls := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(_stdout), "\n") {
+		if path, url, ok := strings.Cut(line, "\t"); ok {
+			urls[path] = url
+		}
+	}
+	return urls
+}
+
+func TestUploadFiles(t *testing.T) {
+	server := newTestServer(t)
+	dir := t.TempDir()
+	store := filepath.Join(dir, "store.json")
+
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	assert.Nil(t, os.WriteFile(a, bytes.Repeat([]byte("a"), 5000), 0o600))
+	assert.Nil(t, os.WriteFile(b, []byte("b"), 0o600))
+
+	e := newTestEnv(nil, map[string]string{endpointEnv: server.url})
+	code := run(context.Background(), &e.env, []string{"upload", "-store", store, "-chunk-size", "1KiB", "-checksum", "sha1",
+		"-m", "team=ops", "-H", "X-Test: 1", "-enrich", "filetype,size", "-verify", a, b})
+	assert.Equal(t, exitOK, code, e.stderr.String())
+
+	urls := uploadedURLs(e.stdout.String())
+	assert.Len(t, urls, 2)
+	assert.Equal(t, bytes.Repeat([]byte("a"), 5000), server.content(t, urls[a]))
+	assert.Equal(t, []byte("b"), server.content(t, urls[b]))
+
+	e = newTestEnv(nil, nil)
+	code = run(context.Background(), &e.env, []string{"info", "-endpoint", server.url, "-store", store, "-json", urls[a]})
+	assert.Equal(t, exitOK, code, e.stderr.String())
+	var info uploadInfo
+	assert.Nil(t, json.Unmarshal(e.stdout.Bytes(), &info))
+	assert.Equal(t, "sha256", info.Metadata["checksumAlgorithm"])
+	delete(info.Metadata, "checksum")
+	delete(info.Metadata, "checksumAlgorithm")
+	assert.Equal(t, tusc.Metadata{"filename": "a.txt", "filetype": "text/plain; charset=utf-8", "size": "5000", "team": "ops"}, info.Metadata)
+
+	// a rerun resumes the completed uploads rather than creating new ones
+	e = newTestEnv(nil, nil)
+	code = run(context.Background(), &e.env, []string{"upload", "-endpoint", server.url, "-store", store, a, b})
+	assert.Equal(t, exitOK, code, e.stderr.String())
+	assert.Equal(t, urls, uploadedURLs(e.stdout.String()))
+}
+
+func TestUploadStdin(t *testing.T) {
+	server := newTestServer(t)
+	store := filepath.Join(t.TempDir(), "store.json")
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	e := newTestEnv(content, nil)
+	code := run(context.Background(), &e.env, []string{"upload", "-endpoint", server.url, "-store", store, "-chunk-size", "4096", "-verify", "-"})
+	assert.Equal(t, exitOK, code, e.stderr.String())
+	assert.Equal(t, content, server.content(t, uploadedURLs(e.stdout.String())["-"]))
+
+	// without a fingerprint stdin uploads aren't resumable, so nothing is kept
+	stored, err := os.ReadFile(store)
+	assert.Nil(t, err)
+	assert.JSONEq(t, "{}", string(stored))
+}
+
+func TestUploadOAuth(t *testing.T) {
+	server := newTestServer(t)
+	store := filepath.Join(t.TempDir(), "store.json")
+
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "tusc" || secret != "s3cret" || r.PostFormValue("scope") != "upload" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "token", "token_type": "bearer", "expires_in": 3600}`))
+	}))
+	defer tokens.Close()
+
+	target, err := url.Parse(server.url)
+	assert.Nil(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: target.Scheme, Host: target.Host})
+	gate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer gate.Close()
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	assert.Nil(t, os.WriteFile(path, []byte("authorized"), 0o600))
+
+	e := newTestEnv(nil, map[string]string{oauthClientIDEnv: "tusc", oauthClientSecretEnv: "s3cret"})
+	code := run(context.Background(), &e.env, []string{"upload", "-endpoint", gate.URL + "/uploads/", "-store", store,
+		"-oauth-token-url", tokens.URL, "-oauth-scope", "upload", path})
+	assert.Equal(t, exitOK, code, e.stderr.String())
+	assert.Equal(t, []byte("authorized"), server.content(t, uploadedURLs(e.stdout.String())[path]))
+}
+
+func TestUploadSigned(t *testing.T) {
+	server := newTestServer(t)
+	store := filepath.Join(t.TempDir(), "store.json")
+
+	signer := &tusc.HMACSigner{Key: []byte("k3y"), KeyID: "ops"}
+	target, err := url.Parse(server.url)
+	assert.Nil(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: target.Scheme, Host: target.Host})
+	gate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := signer.Verify(r, time.Minute); err != nil || r.Header.Get(tusc.DefaultKeyIDHeader) != "ops" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer gate.Close()
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	assert.Nil(t, os.WriteFile(path, []byte("signed"), 0o600))
+
+	e := newTestEnv(nil, map[string]string{signingKeyEnv: "k3y"})
+	code := run(context.Background(), &e.env, []string{"upload", "-endpoint", gate.URL + "/uploads/", "-store", store,
+		"-signing-key-id", "ops", path})
+	assert.Equal(t, exitOK, code, e.stderr.String())
+	assert.Equal(t, []byte("signed"), server.content(t, uploadedURLs(e.stdout.String())[path]))
+
+	e = newTestEnv(nil, map[string]string{signingKeyEnv: "wrong"})
+	code = run(context.Background(), &e.env, []string{"upload", "-endpoint", gate.URL + "/uploads/", "-store", store, path})
+	assert.Equal(t, exitFailure, code)
+}
+
+func TestUploadErrors(t *testing.T) {
+	server := newTestServer(t)
+	store := filepath.Join(t.TempDir(), "store.json")
+
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"upload"},
+		{"upload", "-store", store, "file"},
+		{"upload", "-endpoint", server.url, "-store", store, "-checksum", "crc1", "file"},
+		{"upload", "-endpoint", server.url, "-store", store, "-fingerprint", "x", "a", "b"},
+		{"upload", "-endpoint", server.url, "-store", store, "-m", "file name=a", "file"},
+		{"upload", "-endpoint", server.url, "-store", store, "-enrich", "size,colour", "file"},
+	} {
+		e := newTestEnv(nil, nil)
+		assert.Equal(t, exitUsage, run(context.Background(), &e.env, args), args)
+	}
+
+	e := newTestEnv(nil, nil)
+	code := run(context.Background(), &e.env, []string{"upload", "-endpoint", server.url, "-store", store, "missing"})
+	assert.Equal(t, exitFailure, code)
+	assert.Contains(t, e.stderr.String(), "missing")
+}
+
+func TestParseByteSize(t *testing.T) {
+	for s, expected := range map[string]int64{
+		"1024":  1024,
+		"2KiB":  2048,
+		"5 MiB": 5 * 1024 * 1024,
+		"5m":    5 * 1024 * 1024,
+		"3MB":   3 * 1000 * 1000,
+		"1GiB":  1 << 30,
+		"100B":  100,
+	} {
+		size, err := parseByteSize(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, expected, size, s)
+	}
+
+	for _, s := range []string{"", "MiB", "-1", "1.5MiB", "ten"} {
+		_, err := parseByteSize(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestForwardReader(t *testing.T) {
+	r := &forwardReader{reader: strings.NewReader("0123456789")}
+
+	offset, err := r.Seek(4, 0)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 4, offset)
+
+	buf := make([]byte, 3)
+	_, err = r.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "456", string(buf))
+
+	_, err = r.Seek(0, 0)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/offby0x01/tusc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManageUploads(t *testing.T) {
+	ctx := context.Background()
+	server := newTestServer(t)
+	dir := t.TempDir()
+	store := filepath.Join(dir, "store.json")
+	vars := map[string]string{endpointEnv: server.url}
+
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	assert.Nil(t, os.WriteFile(a, []byte("aaaa"), 0o600))
+	assert.Nil(t, os.WriteFile(b, []byte("bbbb"), 0o600))
+
+	e := newTestEnv(nil, vars)
+	assert.Equal(t, exitOK, run(ctx, &e.env, []string{"upload", "-store", store, "-m", "team=ops", a}), e.stderr.String())
+	urlA := uploadedURLs(e.stdout.String())[a]
+
+	e = newTestEnv(nil, vars)
+	assert.Equal(t, exitOK, run(ctx, &e.env, []string{"upload", "-store", store, "-fingerprint", "b", b}), e.stderr.String())
+	urlB := uploadedURLs(e.stdout.String())[b]
+
+	// info by URL, without an endpoint
+	e = newTestEnv(nil, nil)
+	assert.Equal(t, exitOK, run(ctx, &e.env, []string{"info", "-store", store, "-json", urlA}), e.stderr.String())
+	var info uploadInfo
+	assert.Nil(t, json.Unmarshal(e.stdout.Bytes(), &info))
+	assert.EqualValues(t, 4, info.Offset)
+	assert.EqualValues(t, 4, info.Length)
+	assert.Equal(t, "ops", info.Metadata["team"])
+	assert.Equal(t, "a.txt", info.Metadata["filename"])
+	assert.Len(t, info.Fingerprints, 1)
+
+	// info by the fingerprint given to upload
+	e = newTestEnv(nil, vars)
+	assert.Equal(t, exitOK, run(ctx, &e.env, []string{"info", "-store", store, "b"}), e.stderr.String())
+	assert.Contains(t, e.stdout.String(), urlB)
+	assert.Contains(t, e.stdout.String(), "4 of 4 (100%)")
+
+	e = newTestEnv(nil, vars)
+	assert.Equal(t, exitOK, run(ctx, &e.env, []string{"capabilities", "-store", store, "-json"}), e.stderr.String())
+	assert.Contains(t, e.stdout.String(), `"termination"`)
+
+	e = newTestEnv(nil, vars)
+	assert.Equal(t, exitOK, run(ctx, &e.env, []string{"terminate", "-store", store, urlB}), e.stderr.String())
+
+	// terminating removed b from the store, an entry for a URL that has gone is missing. records kept by watch and
+	//   encrypted uploads are not uploads.
+	missing := server.url + "deadbeef"
+	records := `"watch:a": "{}", "encryption:a": "{}"`
+	assert.Nil(t, os.WriteFile(store, bytes.Replace(mustReadFile(t, store), []byte(`{`), []byte(`{"gone": "`+missing+`", `+records+`,`), 1), 0o600))
+
+	e = newTestEnv(nil, vars)
+	assert.Equal(t, exitOK, run(ctx, &e.env, []string{"list", "-store", store, "-json"}), e.stderr.String())
+	var entries []storeEntry
+	assert.Nil(t, json.Unmarshal(e.stdout.Bytes(), &entries))
+	statuses := make(map[string]string)
+	for _, entry := range entries {
+		statuses[entry.URL] = entry.Status
+	}
+	assert.Equal(t, map[string]string{urlA: statusComplete, missing: statusMissing}, statuses)
+
+	e = newTestEnv(nil, vars)
+	assert.Equal(t, exitOK, run(ctx, &e.env, []string{"prune", "-store", store, "-dry-run"}), e.stderr.String())
+	assert.Contains(t, e.stdout.String(), "would prune gone (missing)")
+
+	e = newTestEnv(nil, vars)
+	assert.Equal(t, exitOK, run(ctx, &e.env, []string{"prune", "-store", store}), e.stderr.String())
+	assert.JSONEq(t, "{"+records+"}", string(mustReadFile(t, store)))
+
+	// nothing left to resolve
+	e = newTestEnv(nil, vars)
+	assert.Equal(t, exitFailure, run(ctx, &e.env, []string{"terminate", "-store", store, "b"}))
+}
+
+func TestCapabilitiesUnknownExtension(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Version", tusc.ProtocolVersion)
+		w.Header().Set("Tus-Extension", "creation,future-extension")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	e := newTestEnv(nil, nil)
+	store := filepath.Join(t.TempDir(), "store.json")
+	assert.Equal(t, exitOK, run(context.Background(), &e.env, []string{"capabilities", "-store", store, server.URL}), e.stderr.String())
+	assert.Contains(t, e.stdout.String(), "extensions: creation, future-extension")
+}
+
+func TestParentURL(t *testing.T) {
+	parent, err := parentURL("https://example.com/files/abc?x=1")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://example.com/files/", parent)
+
+	_, err = parentURL("abc")
+	assert.NotNil(t, err)
+}
+
+func mustReadFile(t *testing.T, _path string) []byte {
+	content, err := os.ReadFile(_path)
+	assert.Nil(t, err)
+	return content
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const progressBarWidth = 30
+
+// progressBar redraws a single status line. The length is negative while an upload is deferred.
+type progressBar struct {
+	w       io.Writer
+	name    string
+	started time.Time
+	// startOffset bytes already on the server when resumed, excluded from the rate
+	startOffset int64
+
+	mu        sync.Mutex
+	lastWidth int
+	finished  bool
+}
+
+func newProgressBar(_w io.Writer, _name string, _startOffset int64) *progressBar {
+	return &progressBar{
+		w:           _w,
+		name:        _name,
+		started:     time.Now(),
+		startOffset: _startOffset,
+	}
+}
+
+func (p *progressBar) update(_offset int64, _size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.finished {
+		p.draw(_offset, _size)
+	}
+}
+
+// finish draws the final state and ends the line, ignoring any later updates
+func (p *progressBar) finish(_offset int64, _size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.finished {
+		p.draw(_offset, _size)
+		fmt.Fprintln(p.w)
+		p.finished = true
+	}
+}
+
+// draw callers must hold mu
+func (p *progressBar) draw(_offset int64, _size int64) {
+	var line string
+	if _size >= 0 {
+		percent := int64(100)
+		if _size > 0 {
+			percent = _offset * 100 / _size
+		}
+		filled := int(percent * progressBarWidth / 100)
+		line = fmt.Sprintf("%s [%s%s] %3d%% %s/%s", p.name, strings.Repeat("=", filled),
+			strings.Repeat(" ", progressBarWidth-filled), percent, formatByteSize(_offset), formatByteSize(_size))
+	} else {
+		line = fmt.Sprintf("%s %s", p.name, formatByteSize(_offset))
+	}
+
+	if elapsed := time.Since(p.started).Seconds(); elapsed > 0 {
+		line += fmt.Sprintf(" %s/s", formatByteSize(int64(float64(_offset-p.startOffset)/elapsed)))
+	}
+
+	// pad over the remains of a longer previous line
+	padding := max(p.lastWidth-len(line), 0)
+	p.lastWidth = len(line)
+	fmt.Fprintf(p.w, "\r%s%s", line, strings.Repeat(" ", padding))
+}
+
+// isTerminal reports whether _w is a character device, progress bars are only drawn on terminals
+func isTerminal(_w io.Writer) bool {
+	file, ok := _w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
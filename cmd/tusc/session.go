@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	netUrl "net/url"
+	"strings"
+
+	"github.com/offby0x01/tusc"
+)
+
+// session holds what commands managing existing uploads share: the store and a client per endpoint
+type session struct {
+	flags   *clientFlags
+	cfg     *tusc.Config
+	store   tusc.ListableStore
+	clients map[string]*tusc.Client
+}
+
+func newSession(_flags *clientFlags) (*session, error) {
+	cfg, store, err := _flags.setup(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session{
+		flags:   _flags,
+		cfg:     cfg,
+		store:   store,
+		clients: make(map[string]*tusc.Client),
+	}, nil
+}
+
+func (s *session) close() {
+	s.store.Close()
+}
+
+// client for requests to _url, created against -endpoint or, if unset, the collection _url belongs to
+func (s *session) client(_url string) (*tusc.Client, error) {
+	endpoint := s.flags.endpoint
+	if endpoint == "" {
+		var err error
+		if endpoint, err = parentURL(_url); err != nil {
+			return nil, err
+		}
+	}
+
+	if client, ok := s.clients[endpoint]; ok {
+		return client, nil
+	}
+
+	client, err := tusc.NewClient(endpoint, s.cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.clients[endpoint] = client
+
+	return client, nil
+}
+
+// resolve an upload URL or fingerprint to the upload URL and the store fingerprints pointing at it
+func (s *session) resolve(_arg string) (string, []string, error) {
+	if strings.Contains(_arg, "://") {
+		return _arg, s.fingerprints(_arg), nil
+	}
+
+	candidates := []string{_arg}
+	if s.flags.endpoint != "" {
+		// "tusc upload" scopes fingerprints to the endpoint
+		candidates = append(candidates, endpointFingerprint(s.flags.endpoint, _arg))
+	}
+
+	for _, fingerprint := range candidates {
+		if url, ok := s.store.Get(fingerprint); ok {
+			return url, s.fingerprints(url), nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("%w: no upload URL for fingerprint %q in %s", tusc.ErrUploadNotFound, _arg, s.flags.store)
+}
+
+// fingerprints of the store entries for _url
+func (s *session) fingerprints(_url string) []string {
+	var fingerprints []string
+	for _, fingerprint := range s.store.Keys() {
+		if !tusc.IsUploadKey(fingerprint) {
+			continue
+		}
+		if url, ok := s.store.Get(fingerprint); ok && url == _url {
+			fingerprints = append(fingerprints, fingerprint)
+		}
+	}
+	return fingerprints
+}
+
+// parentURL of an upload URL, the endpoint it was created at
+func parentURL(_url string) (string, error) {
+	u, err := netUrl.Parse(_url)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", errors.New("not an absolute URL: " + _url)
+	}
+
+	u.Path = u.Path[:strings.LastIndex(u.Path, "/")+1]
+	u.RawQuery = ""
+	return u.String(), nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/offby0x01/tusc"
+)
+
+func runTerminate(_ctx context.Context, _env *env, _args []string) int {
+	var flags clientFlags
+	fs := newFlagSet(_env, "terminate [flags] URL|FINGERPRINT...",
+		"Deletes uploads from the server and removes their store entries.")
+	flags.register(fs, _env)
+
+	if err := fs.Parse(_args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	s, err := newSession(&flags)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitUsage
+	}
+	defer s.close()
+
+	code := exitOK
+	for _, arg := range fs.Args() {
+		url, err := s.terminate(_ctx, arg)
+		if err != nil {
+			fmt.Fprintf(_env.stderr, "tusc: %s: %v\n", arg, err)
+			code = exitFailure
+			continue
+		}
+		fmt.Fprintf(_env.stdout, "terminated %s\n", url)
+	}
+
+	return code
+}
+
+func (s *session) terminate(_ctx context.Context, _arg string) (string, error) {
+	url, fingerprints, err := s.resolve(_arg)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := s.client(url)
+	if err != nil {
+		return "", err
+	}
+
+	err = client.TerminateUpload(_ctx, url)
+	if err == nil || errors.Is(err, tusc.ErrUploadNotFound) {
+		// either way there's nothing left to resume
+		for _, fingerprint := range fingerprints {
+			s.store.Delete(fingerprint)
+		}
+	}
+
+	return url, err
+}
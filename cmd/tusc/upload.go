@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/offby0x01/tusc"
+)
+
+type uploadFlags struct {
+	clientFlags
+	metadata    keyValueFlag
+	fingerprint string
+	noProgress  bool
+}
+
+func runUpload(_ctx context.Context, _env *env, _args []string) int {
+	var flags uploadFlags
+	fs := newFlagSet(_env, "upload [flags] FILE...",
+		"Uploads each FILE, or stdin for \"-\", printing the upload URL. Rerunning an interrupted upload resumes it.")
+	flags.register(fs, _env)
+	fs.Var(&flags.metadata, "m", "upload metadata key=value, repeatable")
+	flags.registerCreate(fs)
+	fs.StringVar(&flags.fingerprint, "fingerprint", "", "identifies the upload for resuming, defaults to the file path, size and mtime. Stdin is only resumable with a fingerprint")
+	fs.BoolVar(&flags.noProgress, "no-progress", false, "don't draw progress bars")
+
+	if err := fs.Parse(_args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if err := tusc.Metadata(flags.metadata).Validate(); err != nil {
+		fmt.Fprintln(_env.stderr, "tusc: -m:", err)
+		return exitUsage
+	}
+	if flags.fingerprint != "" && fs.NArg() > 1 {
+		fmt.Fprintln(_env.stderr, "tusc: -fingerprint applies to a single upload")
+		return exitUsage
+	}
+
+	cfg, store, err := flags.setup(true)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitUsage
+	}
+	defer store.Close()
+
+	client, err := tusc.NewClient(flags.endpoint, cfg)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitFailure
+	}
+
+	code := exitOK
+	for _, path := range fs.Args() {
+		url, err := uploadPath(_ctx, _env, client, &flags, path)
+		if _ctx.Err() != nil {
+			fmt.Fprintf(_env.stderr, "tusc: %s: interrupted, rerun to resume\n", path)
+			return exitInterrupted
+		}
+		if err != nil {
+			fmt.Fprintf(_env.stderr, "tusc: %s: %v\n", path, err)
+			code = exitFailure
+			continue
+		}
+		fmt.Fprintf(_env.stdout, "%s\t%s\n", path, url)
+	}
+
+	return code
+}
+
+// uploadPath uploads a file, or stdin for "-", returning the upload URL
+func uploadPath(_ctx context.Context, _env *env, _client *tusc.Client, _flags *uploadFlags, _path string) (string, error) {
+	upload, closer, err := openUpload(_env, _flags, _path)
+	if err != nil {
+		return "", err
+	}
+	defer closer()
+
+	// scope fingerprints to the endpoint, the same file uploaded elsewhere is a separate upload
+	transient := upload.Fingerprint == ""
+	if transient {
+		upload.Fingerprint = randomFingerprint()
+	}
+	upload.Fingerprint = endpointFingerprint(_flags.endpoint, upload.Fingerprint)
+
+	for k, v := range _flags.metadata {
+		upload.Metadata[k] = v
+	}
+
+	uploadMgr, err := _client.CreateOrResumeUpload(upload)
+	if err != nil {
+		return "", err
+	}
+	if transient {
+		// nothing could resume it
+		defer _client.Config.Store.Delete(upload.Fingerprint)
+	}
+
+	stop := context.AfterFunc(_ctx, uploadMgr.Abort)
+	defer stop()
+
+	if !_flags.noProgress && isTerminal(_env.stderr) {
+		bar := newProgressBar(_env.stderr, _path, upload.Offset())
+		// the manager blocks on subscribers and stops publishing once Upload returns
+		progress := make(chan tusc.Upload)
+		drained := make(chan struct{})
+		uploadMgr.Subscribe(progress)
+		go func() {
+			defer close(drained)
+			for u := range progress {
+				bar.update(u.Offset(), u.Size())
+			}
+		}()
+		defer func() {
+			close(progress)
+			<-drained
+			bar.finish(upload.Offset(), upload.Size())
+		}()
+	}
+
+	if err = uploadMgr.Upload(); err != nil {
+		return "", err
+	}
+
+	return uploadMgr.URL(), nil
+}
+
+// openUpload opens _path, or stdin for "-". Files get a fingerprint from their path, size and mtime, stdin only
+// has one if given with -fingerprint.
+func openUpload(_env *env, _flags *uploadFlags, _path string) (*tusc.Upload, func(), error) {
+	var fingerprint *string
+	if _flags.fingerprint != "" {
+		fingerprint = &_flags.fingerprint
+	}
+
+	if _path != "-" {
+		file, err := os.Open(_path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		upload, err := tusc.NewUploadFromFile(file, fingerprint)
+		if err == nil && fingerprint == nil {
+			err = upload.GenerateFingerprint(&tusc.FileInfoFingerprinter{})
+		}
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return upload, func() { file.Close() }, nil
+	}
+
+	// stdin redirected from a file has a known length
+	if file, ok := _env.stdin.(*os.File); ok {
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+			upload, err := tusc.NewUpload(file, info.Size(), nil, fingerprint)
+			return upload, func() {}, err
+		}
+	}
+
+	upload, err := tusc.NewUpload(&forwardReader{reader: _env.stdin}, tusc.DeferredSize, nil, fingerprint)
+	return upload, func() {}, err
+}
+
+func endpointFingerprint(_endpoint string, _fingerprint string) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(_endpoint))
+	hasher.Write([]byte{0})
+	hasher.Write([]byte(_fingerprint))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// endpointFingerprinter fingerprints files as FileInfoFingerprinter does, scoped to _endpoint
+func endpointFingerprinter(_endpoint string) tusc.Fingerprinter {
+	return tusc.FingerprinterFunc(func(_upload *tusc.Upload) (string, error) {
+		fingerprint, err := (&tusc.FileInfoFingerprinter{}).Fingerprint(_upload)
+		if err != nil {
+			return "", err
+		}
+		return endpointFingerprint(_endpoint, fingerprint), nil
+	})
+}
+
+func randomFingerprint() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// forwardReader makes a pipe usable as an upload stream. Seeking forward discards input, which is how a rerun with
+// the same piped content skips what the server already has. Seeking backwards is impossible.
+type forwardReader struct {
+	reader   io.Reader
+	position int64
+}
+
+func (r *forwardReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.position += int64(n)
+	return n, err
+}
+
+func (r *forwardReader) Seek(_offset int64, _whence int) (int64, error) {
+	switch _whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		_offset += r.position
+	default:
+		return r.position, tusc.ErrSeekUnsupported
+	}
+
+	if _offset < r.position {
+		return r.position, errors.New("stdin cannot be rewound")
+	}
+	if _offset > r.position {
+		if _, err := io.CopyN(io.Discard, r, _offset-r.position); err != nil {
+			return r.position, err
+		}
+	}
+
+	return r.position, nil
+}
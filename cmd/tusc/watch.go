@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/offby0x01/tusc"
+)
+
+var watchActions = map[string]tusc.WatchAction{
+	"mark":   tusc.WatchMark,
+	"move":   tusc.WatchMove,
+	"delete": tusc.WatchDelete,
+}
+
+func runWatch(_ctx context.Context, _env *env, _args []string) int {
+	var flags clientFlags
+	var state, action, moveTo string
+	var include, exclude stringsFlag
+	var hidden, poll bool
+	var workers int
+	var stableFor, pollInterval time.Duration
+	fs := newFlagSet(_env, "watch [flags] DIR",
+		"Uploads files as they appear under DIR until interrupted. Files are uploaded once unchanged for -stable-for, then\n"+
+			"marked, moved or deleted. Uploaded files are recorded in -state so a restart never uploads a file twice.")
+	flags.register(fs, _env)
+	flags.registerCreate(fs)
+	fs.StringVar(&state, "state", filepath.Join(filepath.Dir(defaultStorePath()), "watch.json"), "file recording uploaded files")
+	fs.StringVar(&action, "action", "mark", "what to do with uploaded files: mark (record in -state only), move or delete")
+	fs.StringVar(&moveTo, "move-to", "", "directory uploaded files are moved to with -action move")
+	fs.Var(&include, "include", "glob files must match, repeatable, see -exclude")
+	fs.Var(&exclude, "exclude", `glob of files or directories to skip, repeatable. Globs without a "/" match names, "**" matches directories`)
+	fs.BoolVar(&hidden, "hidden", false, "include files and directories starting with a '.'")
+	fs.BoolVar(&poll, "poll", false, "poll rather than use change notifications, e.g. on network filesystems")
+	fs.IntVar(&workers, "workers", 2, "files uploaded concurrently")
+	fs.DurationVar(&stableFor, "stable-for", 5*time.Second, "how long a file must be unchanged before it is uploaded")
+	fs.DurationVar(&pollInterval, "poll-interval", 2*time.Second, "how often DIR is rescanned")
+
+	if err := fs.Parse(_args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	watchAction, ok := watchActions[action]
+	if !ok {
+		fmt.Fprintf(_env.stderr, "tusc: unknown action %q\n", action)
+		return exitUsage
+	}
+
+	cfg, store, err := flags.setup(true)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitUsage
+	}
+	defer store.Close()
+
+	stateStore, err := tusc.NewFileStore(state)
+	if err != nil {
+		fmt.Fprintf(_env.stderr, "tusc: state %s: %v\n", state, err)
+		return exitUsage
+	}
+	defer stateStore.Close()
+
+	client, err := tusc.NewClient(flags.endpoint, cfg)
+	if err != nil {
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitFailure
+	}
+
+	options := &tusc.WatchOptions{
+		Dir: &tusc.DirOptions{
+			Include:       include,
+			Exclude:       exclude,
+			IncludeHidden: hidden,
+			Fingerprinter: endpointFingerprinter(flags.endpoint),
+			Workers:       workers,
+		},
+		State:        stateStore,
+		Action:       watchAction,
+		MoveTo:       moveTo,
+		StableFor:    stableFor,
+		PollInterval: pollInterval,
+		Poll:         poll,
+		OnEvent: func(_event tusc.WatchEvent) {
+			switch {
+			case _event.Err != nil:
+				fmt.Fprintf(_env.stderr, "tusc: %s: %v\n", _event.Path, _event.Err)
+			case _event.Skipped:
+				fmt.Fprintf(_env.stdout, "skipped\t%s\t%s\n", _event.Path, _event.URL)
+			default:
+				fmt.Fprintf(_env.stdout, "uploaded\t%s\t%s\n", _event.Path, _event.URL)
+			}
+		},
+	}
+
+	err = client.Watch(_ctx, fs.Arg(0), options)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return exitOK
+	case errors.Is(err, tusc.ErrBadWatchAction):
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitUsage
+	default:
+		fmt.Fprintln(_env.stderr, "tusc:", err)
+		return exitFailure
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatch(t *testing.T) {
+	server := newTestServer(t)
+	dir := t.TempDir()
+	watched := filepath.Join(dir, "in")
+	file := filepath.Join(watched, "a.txt")
+	assert.Nil(t, os.MkdirAll(watched, 0o700))
+	assert.Nil(t, os.WriteFile(file, []byte("aaaa"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the watcher writes from its own goroutines, stay off the buffers until it has stopped
+	e := newTestEnv(nil, map[string]string{endpointEnv: server.url})
+	code := make(chan int)
+	go func() {
+		code <- run(ctx, &e.env, []string{"watch", "-store", filepath.Join(dir, "store.json"), "-state", filepath.Join(dir, "state.json"),
+			"-action", "delete", "-stable-for", "10ms", "-poll-interval", "10ms", watched})
+	}()
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(file)
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.Equal(t, exitOK, <-code, e.stderr.String())
+	assert.Contains(t, e.stdout.String(), "uploaded\t"+file+"\t"+server.url)
+}
+
+func TestWatchUsage(t *testing.T) {
+	e := newTestEnv(nil, nil)
+	assert.Equal(t, exitUsage, run(context.Background(), &e.env, []string{"watch", "-endpoint", "http://localhost/", "-action", "shred", t.TempDir()}))
+}
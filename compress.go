@@ -0,0 +1,344 @@
+package tusc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	MetadataContentEncoding        = "contentEncoding"
+	MetadataOriginalSize           = "originalSize"
+	MetadataCompressionSegmentSize = "compressionSegmentSize"
+
+	DefaultCompressionSegmentSize = 1024 * 1024
+)
+
+type Compression int
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZstd
+)
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZstd:
+		return "zstd"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseCompression parses the names returned by Compression.String
+func ParseCompression(_name string) (Compression, error) {
+	for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		if c.String() == _name {
+			return c, nil
+		}
+	}
+	return CompressionNone, ErrBadCompression
+}
+
+// extension appended to file names of content compressed with c
+func (c Compression) extension() string {
+	switch c {
+	case CompressionGzip:
+		return ".gz"
+	case CompressionZstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// newWriter returns a compressor producing identical output for identical input, which resuming relies on
+func (c Compression) newWriter(_w io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case CompressionGzip:
+		// the zero header has no name or mtime, keeping output deterministic
+		return gzip.NewWriter(_w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(_w, zstd.WithEncoderConcurrency(1))
+	default:
+		return nil, ErrBadCompression
+	}
+}
+
+// CompressionOptions for NewCompressedUpload
+type CompressionOptions struct {
+	// SegmentSize [optional] uncompressed bytes per independently compressed segment, defaults to
+	//   DefaultCompressionSegmentSize. Larger segments compress better, smaller ones resume with less rework.
+	SegmentSize int
+}
+
+// NewCompressedUpload wraps _upload so content is compressed before it is sent. Content is compressed in segments,
+// each a complete gzip member or zstd frame, so the result is an ordinary multi-member gzip or multi-frame zstd stream
+// and any offset can be produced by compressing a single segment, which lets uploads resume as usual. Sizing the
+// result needs one compression pass over the content up front.
+//
+// The encoding, original size and segment size are added to Metadata and ".gz" or ".zst" is appended to the
+// filename. The fingerprint is derived from the original fingerprint and the options.
+func NewCompressedUpload(_upload *Upload, _compression Compression, _options *CompressionOptions) (*Upload, error) {
+	if _upload == nil {
+		return nil, ErrNilUpload
+	}
+	if _upload.size < 0 {
+		return nil, ErrDeferredSize
+	}
+	if _options == nil {
+		_options = &CompressionOptions{}
+	}
+
+	segmentSize := int64(_options.SegmentSize)
+	if segmentSize <= 0 {
+		segmentSize = DefaultCompressionSegmentSize
+	}
+
+	compressor, err := newSegmentCompressor(_compression)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &compressedStream{
+		source:      _upload.stream,
+		readerAt:    _upload.readerAt,
+		compressor:  compressor,
+		segmentSize: segmentSize,
+		plainSize:   _upload.size,
+		cached:      -1,
+	}
+	if err = stream.index(); err != nil {
+		return nil, err
+	}
+
+	metadata := make(Metadata, len(_upload.Metadata)+3)
+	for k, v := range _upload.Metadata {
+		metadata[k] = v
+	}
+	metadata[MetadataContentEncoding] = _compression.String()
+	metadata[MetadataOriginalSize] = strconv.FormatInt(_upload.size, 10)
+	metadata[MetadataCompressionSegmentSize] = strconv.FormatInt(segmentSize, 10)
+	if filename, ok := metadata[MetadataFilename]; ok {
+		metadata[MetadataFilename] = filename + _compression.extension()
+	}
+
+	var fingerprint *string
+	if _upload.Fingerprint != "" {
+		hasher := sha256.New()
+		hasher.Write([]byte(_upload.Fingerprint))
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(_compression.String()))
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(strconv.FormatInt(segmentSize, 10)))
+		compressed := hex.EncodeToString(hasher.Sum(nil))
+		fingerprint = &compressed
+	}
+
+	return NewUpload(stream, stream.size(), metadata, fingerprint)
+}
+
+// NewDecompressReader returns a reader decompressing content uploaded with NewCompressedUpload, _metadata being the
+// upload metadata
+func NewDecompressReader(_reader io.Reader, _metadata Metadata) (io.ReadCloser, error) {
+	compression, err := ParseCompression(_metadata[MetadataContentEncoding])
+	if err != nil {
+		return nil, ErrContentEncoding
+	}
+
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewReader(_reader)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(_reader)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return io.NopCloser(_reader), nil
+	}
+}
+
+// segmentCompressor compresses each segment into a standalone gzip member or zstd frame
+type segmentCompressor struct {
+	gzip   *gzip.Writer
+	zstd   *zstd.Encoder
+	buffer []byte
+}
+
+func newSegmentCompressor(_compression Compression) (*segmentCompressor, error) {
+	switch _compression {
+	case CompressionGzip:
+		return &segmentCompressor{gzip: gzip.NewWriter(io.Discard)}, nil
+	case CompressionZstd:
+		encoder, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return &segmentCompressor{zstd: encoder}, nil
+	default:
+		return nil, ErrBadCompression
+	}
+}
+
+// compress returns the compressed segment, valid until the next call
+func (c *segmentCompressor) compress(_p []byte) ([]byte, error) {
+	if c.zstd != nil {
+		c.buffer = c.zstd.EncodeAll(_p, c.buffer[:0])
+		return c.buffer, nil
+	}
+
+	buffer := bytes.NewBuffer(c.buffer[:0])
+	c.gzip.Reset(buffer)
+	if _, err := c.gzip.Write(_p); err != nil {
+		return nil, err
+	}
+	if err := c.gzip.Close(); err != nil {
+		return nil, err
+	}
+	c.buffer = buffer.Bytes()
+	return c.buffer, nil
+}
+
+// compressedStream produces compressed content at any offset by compressing only the segment containing it, using
+// an index of compressed segment offsets built up front. The most recent segment is cached.
+type compressedStream struct {
+	source      io.ReadSeeker
+	readerAt    io.ReaderAt
+	compressor  *segmentCompressor
+	segmentSize int64
+	plainSize   int64
+	// offsets of each compressed segment followed by the total size
+	offsets []int64
+	// digests of each compressed segment, a segment compressing differently later means the source changed
+	digests  [][sha256.Size]byte
+	position int64
+
+	mu        sync.Mutex
+	cached    int64
+	cache     []byte
+	plaintext []byte
+}
+
+func (s *compressedStream) index() error {
+	// empty content is still one segment, an empty gzip stream is not valid
+	segments := max((s.plainSize+s.segmentSize-1)/s.segmentSize, 1)
+	s.offsets = make([]int64, 0, segments+1)
+	s.digests = make([][sha256.Size]byte, 0, segments)
+
+	var offset int64
+	for i := int64(0); i < segments; i++ {
+		segment, err := s.segment(i)
+		if err != nil {
+			return err
+		}
+		s.offsets = append(s.offsets, offset)
+		s.digests = append(s.digests, sha256.Sum256(segment))
+		offset += int64(len(segment))
+	}
+	s.offsets = append(s.offsets, offset)
+
+	return nil
+}
+
+func (s *compressedStream) size() int64 {
+	return s.offsets[len(s.offsets)-1]
+}
+
+func (s *compressedStream) Read(p []byte) (int, error) {
+	n, err := s.ReadAt(p, s.position)
+	s.position += int64(n)
+	if errors.Is(err, io.EOF) && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (s *compressedStream) Seek(_offset int64, _whence int) (int64, error) {
+	switch _whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		_offset += s.position
+	case io.SeekEnd:
+		_offset += s.size()
+	default:
+		return s.position, ErrSeekUnsupported
+	}
+	if _offset < 0 {
+		return s.position, ErrSeekUnsupported
+	}
+
+	s.position = _offset
+	return s.position, nil
+}
+
+// ReadAt is safe for concurrent use if the source upload supported random access
+func (s *compressedStream) ReadAt(p []byte, _offset int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	read := 0
+	for read < len(p) {
+		if _offset >= s.size() {
+			return read, io.EOF
+		}
+
+		// the last segment starting at or before _offset
+		index := int64(sort.Search(len(s.offsets), func(i int) bool { return s.offsets[i] > _offset }) - 1)
+		segment, err := s.segment(index)
+		if err != nil {
+			return read, err
+		}
+
+		start := _offset - s.offsets[index]
+		n := copy(p[read:], segment[start:])
+		read += n
+		_offset += int64(n)
+	}
+
+	return read, nil
+}
+
+// segment compresses segment _index, callers must hold mu once the index is built
+func (s *compressedStream) segment(_index int64) ([]byte, error) {
+	if s.cached == _index {
+		return s.cache, nil
+	}
+
+	start := _index * s.segmentSize
+	length := min(s.segmentSize, s.plainSize-start)
+	if cap(s.plaintext) < int(length) {
+		s.plaintext = make([]byte, s.segmentSize)
+	}
+	plaintext := s.plaintext[:length]
+
+	if err := readFullAt(s.source, s.readerAt, plaintext, start, s.plainSize); err != nil {
+		return nil, err
+	}
+
+	compressed, err := s.compressor.compress(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if _index < int64(len(s.digests)) && sha256.Sum256(compressed) != s.digests[_index] {
+		return nil, ErrSourceChanged
+	}
+	s.cache = append(s.cache[:0], compressed...)
+	s.cached = _index
+
+	return s.cache, nil
+}
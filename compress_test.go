@@ -0,0 +1,109 @@
+package tusc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCompression(t *testing.T) {
+	for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		parsed, err := ParseCompression(c.String())
+		assert.Nil(t, err)
+		assert.Equal(t, c, parsed)
+	}
+
+	_, err := ParseCompression("lz4")
+	assert.ErrorIs(t, err, ErrBadCompression)
+}
+
+func TestCompressedUploadRoundTrip(t *testing.T) {
+	for _, compression := range []Compression{CompressionGzip, CompressionZstd} {
+		for _, size := range []int{0, 1, 64, 1000} {
+			content := bytes.Repeat([]byte("abcdefgh"), size)[:size]
+			fingerprint := "fingerprint-TestCompressedUploadRoundTrip"
+			upload, err := NewUploadFromBytes(content, &fingerprint)
+			assert.Nil(t, err)
+
+			compressed, err := NewCompressedUpload(upload, compression, &CompressionOptions{SegmentSize: 64})
+			assert.Nil(t, err)
+			assert.Equal(t, compression.String(), compressed.Metadata[MetadataContentEncoding])
+			assert.Equal(t, fmt.Sprint(size), compressed.Metadata[MetadataOriginalSize])
+			assert.NotEqual(t, fingerprint, compressed.Fingerprint)
+
+			data, err := io.ReadAll(compressed.stream)
+			assert.Nil(t, err)
+			assert.EqualValues(t, len(data), compressed.Size())
+
+			reader, err := NewDecompressReader(bytes.NewReader(data), compressed.Metadata)
+			assert.Nil(t, err)
+			decompressed, err := io.ReadAll(reader)
+			assert.Nil(t, err)
+			assert.Equal(t, content, decompressed, "%s size %d", compression, size)
+		}
+	}
+}
+
+func TestCompressedUploadRandomAccess(t *testing.T) {
+	upload, err := NewUploadFromBytes(bytes.Repeat([]byte("0123456789"), 100), nil)
+	assert.Nil(t, err)
+
+	compressed, err := NewCompressedUpload(upload, CompressionGzip, &CompressionOptions{SegmentSize: 100})
+	assert.Nil(t, err)
+
+	data, err := io.ReadAll(compressed.stream)
+	assert.Nil(t, err)
+
+	for _, offset := range []int64{int64(len(data)) - 5, 3, 100, 0} {
+		_, err = compressed.stream.Seek(offset, io.SeekStart)
+		assert.Nil(t, err)
+		rest, err := io.ReadAll(compressed.stream)
+		assert.Nil(t, err)
+		assert.Equal(t, data[offset:], rest)
+	}
+
+	section, err := io.ReadAll(io.NewSectionReader(compressed.readerAt, 30, 90))
+	assert.Nil(t, err)
+	assert.Equal(t, data[30:120], section)
+}
+
+func TestCompressedUploadSourceChanged(t *testing.T) {
+	// random content is stored rather than compressed by gzip, so changing it keeps every segment the same length
+	content := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(content)
+	upload, err := NewUploadFromBytes(content, nil)
+	assert.Nil(t, err)
+
+	compressed, err := NewCompressedUpload(upload, CompressionGzip, &CompressionOptions{SegmentSize: 100})
+	assert.Nil(t, err)
+
+	content[150] ^= 0xff
+	_, err = io.ReadAll(compressed.stream)
+	assert.ErrorIs(t, err, ErrSourceChanged)
+
+	changed, err := NewCompressedUpload(upload, CompressionGzip, &CompressionOptions{SegmentSize: 100})
+	assert.Nil(t, err)
+	assert.Equal(t, compressed.Size(), changed.Size())
+}
+
+func TestCompressedUploadErrors(t *testing.T) {
+	_, err := NewCompressedUpload(nil, CompressionGzip, nil)
+	assert.ErrorIs(t, err, ErrNilUpload)
+
+	upload, err := NewUploadFromBytes([]byte("1234567890"), nil)
+	assert.Nil(t, err)
+	_, err = NewCompressedUpload(upload, CompressionNone, nil)
+	assert.ErrorIs(t, err, ErrBadCompression)
+
+	deferred, err := NewUpload(bytes.NewReader(nil), DeferredSize, nil, nil)
+	assert.Nil(t, err)
+	_, err = NewCompressedUpload(deferred, CompressionGzip, nil)
+	assert.ErrorIs(t, err, ErrDeferredSize)
+
+	_, err = NewDecompressReader(bytes.NewReader(nil), Metadata{})
+	assert.ErrorIs(t, err, ErrContentEncoding)
+}
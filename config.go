@@ -3,6 +3,7 @@ package tusc
 import (
 	"hash"
 	"net/http"
+	"time"
 )
 
 type Config struct {
@@ -18,17 +19,61 @@ type Config struct {
 	Store Store
 	// ChecksumName [optional] Common name of algorithm to use. If set, ChecksumAlgFunc must also be set.
 	ChecksumAlg string
-	// ChecksumFunc [optional] hash.Hash function to use. If set, ChecksumAlgName must also be set.
-	ChecksumFunc *hash.Hash
+	// ChecksumFunc [optional] returns a new hash.Hash for each chunk, so uploads may run concurrently. If set,
+	//   ChecksumAlg must also be set.
+	ChecksumFunc func() hash.Hash
+	// Fingerprinter [optional] used to generate a fingerprint for uploads created without one
+	Fingerprinter Fingerprinter
+	// UploadMode [optional] how UploadMgr sends data, defaults to UploadModeChunked
+	UploadMode UploadMode
+	// StreamCheckpointBytes [optional] in UploadModeStreaming the server offset is fetched with HEAD and published
+	//   every StreamCheckpointBytes sent
+	StreamCheckpointBytes int64
+	// StreamUnknownLength [optional] send streaming PATCH bodies without a Content-Length (chunked transfer encoding)
+	StreamUnknownLength bool
+	// Retries [optional] number of times an interrupted upload is resumed from the server reported offset, 0 defaults
+	// to 3 and a negative value disables resuming
+	Retries int
+	// ChunkTimeout [optional] deadline for each PATCH in chunked modes, 0 disables
+	ChunkTimeout time.Duration
+	// MinChunkSizeBytes [optional] smallest chunk UploadModeAdaptive will shrink to
+	MinChunkSizeBytes int64
+	// MaxChunkSizeBytes [optional] largest chunk UploadModeAdaptive will grow to
+	MaxChunkSizeBytes int64
+	// RateLimiter [optional] caps the combined bandwidth of PATCH bodies for all uploads of the client, applied on top
+	//   of any UploadMgr.SetRateLimiter limit
+	RateLimiter *RateLimiter
+	// MaxMetadataBytes [optional] largest encoded Upload-Metadata header CreateUpload sends, defaults to
+	// DefaultMaxMetadataBytes
+	MaxMetadataBytes int
+	// MetadataEnrichers [optional] run in order by CreateUpload to add metadata, after the fingerprint is resolved
+	MetadataEnrichers []MetadataEnricher
+	// Integrity [optional] verify each upload as a whole once complete, see IntegrityOptions
+	Integrity *IntegrityOptions
+	// Middleware [optional] wraps every request e.g. for auth, request IDs, logging, metrics or fault injection. The
+	// first is the outermost, see ChainMiddleware.
+	Middleware []Middleware
+	// Authenticator [optional] authorizes every request, refreshing credentials and re-sending once after a 401.
+	// Unlike Header it can renew credentials that expire during long uploads, see OAuth2Authenticator.
+	Authenticator Authenticator
+	// Signer [optional] signs every request last, including re-sends, see HMACSigner
+	Signer Signer
 }
 
+const defaultRetries = 3
+
 func DefaultConfig() *Config {
 	return &Config{
-		ChunkSizeBytes:      5 * 1024 * 1024,
-		HTTPMethodOverrides: nil,
-		Header:              make(http.Header),
-		Store:               NewMemoryStore(),
-		HttpClient:          &http.Client{},
+		ChunkSizeBytes:        5 * 1024 * 1024,
+		HTTPMethodOverrides:   nil,
+		Header:                make(http.Header),
+		Store:                 NewMemoryStore(),
+		HttpClient:            &http.Client{},
+		StreamCheckpointBytes: 5 * 1024 * 1024,
+		Retries:               defaultRetries,
+		MinChunkSizeBytes:     defaultMinChunkSizeBytes,
+		MaxChunkSizeBytes:     defaultMaxChunkSizeBytes,
+		MaxMetadataBytes:      DefaultMaxMetadataBytes,
 	}
 }
 
@@ -58,9 +103,43 @@ func (c *Config) ValidateAndSetDefaults() error {
 		c.Header = make(http.Header)
 	}
 
+	if c.UploadMode < UploadModeChunked || c.UploadMode > UploadModeAdaptive {
+		return ErrBadUploadMode
+	}
+
+	if c.StreamCheckpointBytes <= 0 {
+		c.StreamCheckpointBytes = c.ChunkSizeBytes
+	}
+
+	if c.Retries == 0 {
+		c.Retries = defaultRetries
+	}
+
+	if c.MinChunkSizeBytes <= 0 {
+		c.MinChunkSizeBytes = min(defaultMinChunkSizeBytes, c.ChunkSizeBytes)
+	}
+
+	if c.MaxChunkSizeBytes <= 0 {
+		c.MaxChunkSizeBytes = max(defaultMaxChunkSizeBytes, c.ChunkSizeBytes)
+	}
+
+	if c.MinChunkSizeBytes > c.MaxChunkSizeBytes {
+		return ErrChunkSizeBounds
+	}
+
 	if c.HttpClient == nil {
 		c.HttpClient = &http.Client{}
 	}
 
+	for _, middleware := range c.Middleware {
+		if middleware == nil {
+			return ErrNilMiddleware
+		}
+	}
+
+	if c.MaxMetadataBytes <= 0 {
+		c.MaxMetadataBytes = DefaultMaxMetadataBytes
+	}
+
 	return nil
 }
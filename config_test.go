@@ -17,3 +17,14 @@ func TestConfigDefaultValid(t *testing.T) {
 	c := DefaultConfig()
 	assert.Nil(t, c.ValidateAndSetDefaults())
 }
+
+func TestConfigRetriesDefault(t *testing.T) {
+	c := DefaultConfig()
+	c.Retries = 0
+	assert.Nil(t, c.ValidateAndSetDefaults())
+	assert.Equal(t, defaultRetries, c.Retries)
+
+	c.Retries = -1
+	assert.Nil(t, c.ValidateAndSetDefaults())
+	assert.Equal(t, -1, c.Retries)
+}
@@ -0,0 +1,304 @@
+package tusc
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type SymlinkPolicy int
+
+const (
+	// SymlinkSkip ignores symlinks
+	SymlinkSkip SymlinkPolicy = iota
+	// SymlinkFollow uploads the target of symlinks. Every directory is walked once, under the first path reaching it in
+	// lexical order, so a link to a directory inside the root does not upload its files twice.
+	SymlinkFollow
+)
+
+// DirOptions control which files WalkDir and Client.UploadDir include
+type DirOptions struct {
+	// Include [optional] globs a file must match one of, all files if empty. See MatchGlob.
+	Include []string
+	// Exclude [optional] globs excluding matching files, or whole directories
+	Exclude []string
+	// Symlinks how symlinks are handled, defaults to SymlinkSkip
+	Symlinks SymlinkPolicy
+	// IncludeHidden include files and directories whose name starts with a '.'
+	IncludeHidden bool
+	// Fingerprinter [optional] defaults to Config.Fingerprinter, or FileInfoFingerprinter if that is unset
+	Fingerprinter Fingerprinter
+	// Workers number of files uploaded concurrently, defaults to 1
+	Workers int
+	// OnProgress [optional] called every ProgressInterval while uploading
+	OnProgress func(QueueProgress)
+	// ProgressInterval defaults to 1 second
+	ProgressInterval time.Duration
+}
+
+// DirFile a file found by WalkDir
+type DirFile struct {
+	// Path on disk, symlinks are not resolved
+	Path string
+	// RelativePath slash separated path relative to the walked root
+	RelativePath string
+}
+
+// WalkDir lists the files under _root selected by _options, in lexical order
+func WalkDir(_root string, _options *DirOptions) ([]DirFile, error) {
+	if _options == nil {
+		_options = &DirOptions{}
+	}
+
+	for _, pattern := range append(append([]string(nil), _options.Include...), _options.Exclude...) {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return nil, err
+		}
+	}
+
+	w := &dirWalker{
+		options: _options,
+		visited: make(map[string]bool),
+	}
+
+	if err := w.walk(_root, ""); err != nil {
+		return nil, err
+	}
+
+	return w.files, nil
+}
+
+type dirWalker struct {
+	options *DirOptions
+	visited map[string]bool
+	files   []DirFile
+}
+
+func (w *dirWalker) walk(_dir string, _prefix string) error {
+	// guard against symlink cycles
+	resolved, err := filepath.EvalSymlinks(_dir)
+	if err != nil {
+		return err
+	}
+	if w.visited[resolved] {
+		return nil
+	}
+	w.visited[resolved] = true
+
+	// filepath.WalkDir won't descend into a symlinked root, so walk the target and map paths back under _dir
+	return filepath.WalkDir(resolved, func(_path string, _entry fs.DirEntry, _err error) error {
+		if _err != nil {
+			return _err
+		}
+
+		rel, err := filepath.Rel(resolved, _path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		// WalkDir does not follow links, so directories it reaches are at their real path
+		realPath := _path
+		_path = filepath.Join(_dir, rel)
+		rel = path.Join(_prefix, filepath.ToSlash(rel))
+
+		if !w.options.IncludeHidden && strings.HasPrefix(_entry.Name(), ".") {
+			if _entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if matchAny(w.options.Exclude, rel) {
+			if _entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if _entry.IsDir() {
+			if w.visited[realPath] {
+				return filepath.SkipDir
+			}
+			w.visited[realPath] = true
+			return nil
+		}
+
+		mode := _entry.Type()
+		if mode&fs.ModeSymlink != 0 {
+			if w.options.Symlinks != SymlinkFollow {
+				return nil
+			}
+
+			target, err := os.Stat(_path)
+			if err != nil {
+				// dangling link
+				return nil
+			}
+			if target.IsDir() {
+				return w.walk(_path, rel)
+			}
+			mode = target.Mode().Type()
+		}
+
+		if !mode.IsRegular() {
+			return nil
+		}
+
+		if len(w.options.Include) != 0 && !matchAny(w.options.Include, rel) {
+			return nil
+		}
+
+		w.files = append(w.files, DirFile{
+			Path:         _path,
+			RelativePath: rel,
+		})
+		return nil
+	})
+}
+
+func matchAny(_patterns []string, _rel string) bool {
+	for _, pattern := range _patterns {
+		if MatchGlob(pattern, _rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob reports whether the slash separated relative path _rel matches _pattern. Patterns without a '/' match
+// the file name at any depth, others match the whole path where a "**" segment matches any number of directories.
+// Otherwise the syntax is that of path.Match.
+func MatchGlob(_pattern string, _rel string) bool {
+	if !strings.Contains(_pattern, "/") {
+		ok, _ := path.Match(_pattern, path.Base(_rel))
+		return ok
+	}
+
+	return matchSegments(strings.Split(_pattern, "/"), strings.Split(_rel, "/"))
+}
+
+func matchSegments(_pattern []string, _rel []string) bool {
+	for len(_pattern) != 0 {
+		if _pattern[0] == "**" {
+			for i := 0; i <= len(_rel); i++ {
+				if matchSegments(_pattern[1:], _rel[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(_rel) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(_pattern[0], _rel[0]); !ok {
+			return false
+		}
+
+		_pattern, _rel = _pattern[1:], _rel[1:]
+	}
+
+	return len(_rel) == 0
+}
+
+// UploadDir uploads every file WalkDir selects, adding a "relativePath" metadata entry to each. Files are opened
+// only when a worker is ready for them. Cancelling _ctx cancels every remaining upload.
+func (c *Client) UploadDir(_ctx context.Context, _root string, _options *DirOptions) (QueueSummary, error) {
+	if _options == nil {
+		_options = &DirOptions{}
+	}
+
+	files, err := WalkDir(_root, _options)
+	if err != nil {
+		return QueueSummary{}, err
+	}
+
+	fingerprinter := _options.Fingerprinter
+	if fingerprinter == nil {
+		fingerprinter = c.Config.Fingerprinter
+	}
+	if fingerprinter == nil {
+		fingerprinter = &FileInfoFingerprinter{}
+	}
+
+	queue, err := NewUploadQueue(c, max(_options.Workers, 1))
+	if err != nil {
+		return QueueSummary{}, err
+	}
+
+	for _, file := range files {
+		_, err = queue.AddFunc(func() (*Upload, error) {
+			return openDirFile(file, fingerprinter)
+		}, 0)
+		if err != nil {
+			return QueueSummary{}, err
+		}
+	}
+
+	stop := context.AfterFunc(_ctx, queue.CancelAll)
+	defer stop()
+
+	if _options.OnProgress == nil {
+		return queue.Wait(), nil
+	}
+
+	stopReporting := reportProgress(queue, _options.OnProgress, _options.ProgressInterval)
+	summary := queue.Wait()
+	stopReporting()
+
+	return summary, nil
+}
+
+// reportProgress calls _onProgress every _interval until the returned func is called, which reports once more
+func reportProgress(_queue *UploadQueue, _onProgress func(QueueProgress), _interval time.Duration) func() {
+	if _interval <= 0 {
+		_interval = time.Second
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(_interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				_onProgress(_queue.Progress())
+				return
+			case <-ticker.C:
+				_onProgress(_queue.Progress())
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func openDirFile(_file DirFile, _fingerprinter Fingerprinter) (*Upload, error) {
+	file, err := os.Open(_file.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	upload, err := NewUploadFromFileWithFingerprinter(file, _fingerprinter)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	upload.Metadata[MetadataRelativePath] = _file.RelativePath
+
+	return upload, nil
+}
@@ -0,0 +1,79 @@
+package tusc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestTree(t *testing.T, _root string, _files ...string) {
+	for _, file := range _files {
+		path := filepath.Join(_root, filepath.FromSlash(file))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		assert.Nil(t, os.WriteFile(path, []byte(file), 0o600))
+	}
+}
+
+func relativePaths(_files []DirFile) []string {
+	var paths []string
+	for _, file := range _files {
+		paths = append(paths, file.RelativePath)
+	}
+	return paths
+}
+
+func TestMatchGlob(t *testing.T) {
+	assert.True(t, MatchGlob("*.txt", "a/b/c.txt"))
+	assert.False(t, MatchGlob("*.txt", "a/b/c.csv"))
+	assert.True(t, MatchGlob("a/*/c.txt", "a/b/c.txt"))
+	assert.False(t, MatchGlob("a/*/c.txt", "a/b/d/c.txt"))
+	assert.True(t, MatchGlob("a/**/c.txt", "a/c.txt"))
+	assert.True(t, MatchGlob("a/**/c.txt", "a/b/d/c.txt"))
+	assert.True(t, MatchGlob("**/tmp", "x/y/tmp"))
+}
+
+func TestWalkDir(t *testing.T) {
+	root := t.TempDir()
+	writeTestTree(t, root, "a.txt", "b.csv", ".hidden", "sub/c.txt", "sub/.git/config", "skip/d.txt")
+
+	files, err := WalkDir(root, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.txt", "b.csv", "skip/d.txt", "sub/c.txt"}, relativePaths(files))
+
+	files, err = WalkDir(root, &DirOptions{Include: []string{"*.txt"}, Exclude: []string{"skip"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.txt", "sub/c.txt"}, relativePaths(files))
+
+	files, err = WalkDir(root, &DirOptions{IncludeHidden: true, Include: []string{"**/config", ".hidden"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{".hidden", "sub/.git/config"}, relativePaths(files))
+
+	_, err = WalkDir(root, &DirOptions{Include: []string{"["}})
+	assert.NotNil(t, err)
+}
+
+func TestWalkDirSymlinks(t *testing.T) {
+	root := t.TempDir()
+	writeTestTree(t, root, "real/a.txt")
+	assert.Nil(t, os.Symlink(filepath.Join(root, "real"), filepath.Join(root, "linked")))
+	assert.Nil(t, os.Symlink(filepath.Join(root, "real", "a.txt"), filepath.Join(root, "b.txt")))
+	// cycle back to the root
+	assert.Nil(t, os.Symlink(root, filepath.Join(root, "real", "loop")))
+
+	files, err := WalkDir(root, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"real/a.txt"}, relativePaths(files))
+
+	// real/ is reached through linked/ first and not walked again
+	files, err = WalkDir(root, &DirOptions{Symlinks: SymlinkFollow})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b.txt", "linked/a.txt"}, relativePaths(files))
+	assert.Equal(t, filepath.Join(root, "linked", "a.txt"), files[1].Path)
+
+	assert.Nil(t, os.Rename(filepath.Join(root, "linked"), filepath.Join(root, "z")))
+	files, err = WalkDir(root, &DirOptions{Symlinks: SymlinkFollow})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b.txt", "real/a.txt"}, relativePaths(files))
+}
@@ -0,0 +1,303 @@
+package tusc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultDownloadPartSize = 8 * 1024 * 1024
+	downloadBufferSize      = 64 * 1024
+)
+
+// DownloadOptions control Client.Download
+type DownloadOptions struct {
+	// Offset bytes already downloaded, e.g. DownloadProgress.Contiguous of an interrupted download
+	Offset int64
+	// Workers number of ranges fetched in parallel, defaults to 1
+	Workers int
+	// PartSize [optional] bytes per ranged request, defaults to 8MiB
+	PartSize int64
+	// Checksum [optional] hash verified against ExpectedChecksum once complete. The content is read back from the
+	//   writer, which must implement io.ReaderAt.
+	Checksum         func() hash.Hash
+	ExpectedChecksum []byte
+	// OnProgress [optional] called after every write, never concurrently
+	OnProgress func(DownloadProgress)
+}
+
+// DownloadProgress of Client.Download
+type DownloadProgress struct {
+	// Written bytes written by this call
+	Written int64
+	// Contiguous bytes from the start which are complete, where to resume from if interrupted
+	Contiguous int64
+	Size       int64
+}
+
+// Download fetches a completed upload with GET, as served by tusd and others, writing it to _w. Content is fetched
+// in ranges, in parallel if DownloadOptions.Workers is set, and each range resumes from where it was interrupted up
+// to Config.Retries times. Servers ignoring Range are read sequentially. Returns the size of the upload.
+func (c *Client) Download(_ctx context.Context, _url string, _w io.WriterAt, _options *DownloadOptions) (int64, error) {
+	if _options == nil {
+		_options = &DownloadOptions{}
+	}
+	if _options.Checksum != nil {
+		if _, ok := _w.(io.ReaderAt); !ok {
+			return 0, ErrChecksumUnverifiable
+		}
+	}
+
+	info, err := c.GetUploadInfo(_ctx, _url)
+	if err != nil {
+		return 0, err
+	}
+	if !info.Complete() {
+		return 0, fmt.Errorf("%w: %d of %d bytes", ErrUploadIncomplete, info.Offset, info.Length)
+	}
+
+	d := newDownload(c, _url, _w, info.Length, _options)
+	if err = d.run(_ctx); err != nil {
+		return info.Length, err
+	}
+
+	if _options.Checksum != nil {
+		hasher := _options.Checksum()
+		if _, err = io.Copy(hasher, io.NewSectionReader(_w.(io.ReaderAt), 0, info.Length)); err != nil {
+			return info.Length, err
+		}
+		if sum := hasher.Sum(nil); !bytes.Equal(sum, _options.ExpectedChecksum) {
+			return info.Length, fmt.Errorf("%w: got %x, expected %x", ErrChecksumMismatch, sum, _options.ExpectedChecksum)
+		}
+	}
+
+	return info.Length, nil
+}
+
+type downloadPart struct {
+	start int64
+	end   int64
+	// done bytes of the part written
+	done int64
+}
+
+type download struct {
+	client   *Client
+	url      string
+	w        io.WriterAt
+	size     int64
+	options  *DownloadOptions
+	parts    []*downloadPart
+	partSize int64
+
+	mu      sync.Mutex
+	written int64
+}
+
+func newDownload(_client *Client, _url string, _w io.WriterAt, _size int64, _options *DownloadOptions) *download {
+	d := &download{
+		client:   _client,
+		url:      _url,
+		w:        _w,
+		size:     _size,
+		options:  _options,
+		partSize: _options.PartSize,
+	}
+	if d.partSize <= 0 {
+		d.partSize = defaultDownloadPartSize
+	}
+
+	for start := max(_options.Offset, 0); start < _size; start += d.partSize {
+		d.parts = append(d.parts, &downloadPart{start: start, end: min(start+d.partSize, _size)})
+	}
+
+	return d
+}
+
+// errWholeBody the server ignored Range and the whole content has been written
+var errWholeBody = errors.New("whole body written")
+
+func (d *download) run(_ctx context.Context) error {
+	if len(d.parts) == 0 {
+		return nil
+	}
+
+	// the first part shows whether the server supports ranges before fanning out
+	if err := d.fetch(_ctx, d.parts[0], true); errors.Is(err, errWholeBody) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancelCause(_ctx)
+	defer cancel(nil)
+
+	parts := make(chan *downloadPart)
+	var wg sync.WaitGroup
+	for i := 0; i < max(d.options.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for part := range parts {
+				if err := d.fetch(ctx, part, false); err != nil {
+					cancel(err)
+				}
+			}
+		}()
+	}
+
+	for _, part := range d.parts[1:] {
+		select {
+		case parts <- part:
+		case <-ctx.Done():
+		}
+	}
+	close(parts)
+	wg.Wait()
+
+	return context.Cause(ctx)
+}
+
+// fetch downloads the rest of _part, resuming after failures up to Config.Retries times
+func (d *download) fetch(_ctx context.Context, _part *downloadPart, _first bool) error {
+	var err error
+	for attempt := 0; attempt <= max(d.client.Config.Retries, 0); attempt++ {
+		if err = d.fetchOnce(_ctx, _part, _first); err == nil || !isRetryableDownload(_ctx, err) {
+			return err
+		}
+	}
+	return err
+}
+
+func isRetryableDownload(_ctx context.Context, _err error) bool {
+	return _ctx.Err() == nil && !errors.Is(_err, errWholeBody) && !errors.Is(_err, ErrUploadNotFound) &&
+		!errors.Is(_err, ErrBadContentRange)
+}
+
+func (d *download) fetchOnce(_ctx context.Context, _part *downloadPart, _first bool) error {
+	start := _part.start + d.partDone(_part)
+	if start >= _part.end {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(_ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, _part.end-1))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		if rangeStart, err := contentRangeStart(res.Header.Get("Content-Range")); err != nil {
+			return err
+		} else if rangeStart != start {
+			return fmt.Errorf("%w: requested %d, got %d", ErrBadContentRange, start, rangeStart)
+		}
+		return d.copy(_part, res.Body, start, _part.end)
+	case http.StatusOK:
+		if !_first {
+			return fmt.Errorf("%w: range ignored", ErrBadContentRange)
+		}
+		// no range support, skip what is already written and take the rest in one go
+		if _, err = io.CopyN(io.Discard, res.Body, start); err != nil {
+			return err
+		}
+		d.mu.Lock()
+		_part.end = d.size
+		d.parts = d.parts[:1]
+		d.mu.Unlock()
+		if err = d.copy(_part, res.Body, start, d.size); err != nil {
+			return err
+		}
+		return errWholeBody
+	case http.StatusNotFound, http.StatusGone, http.StatusForbidden:
+		return ErrUploadNotFound
+	default:
+		return newClientError(res)
+	}
+}
+
+// copy writes _body to _w from _offset up to _end, tracking progress in _part
+func (d *download) copy(_part *downloadPart, _body io.Reader, _offset int64, _end int64) error {
+	buf := make([]byte, downloadBufferSize)
+	for _offset < _end {
+		n, err := _body.Read(buf[:min(int64(len(buf)), _end-_offset)])
+		if n > 0 {
+			if _, writeErr := d.w.WriteAt(buf[:n], _offset); writeErr != nil {
+				return writeErr
+			}
+			_offset += int64(n)
+			d.progress(_part, int64(n))
+		}
+		if errors.Is(err, io.EOF) {
+			if _offset < _end {
+				return io.ErrUnexpectedEOF
+			}
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *download) partDone(_part *downloadPart) int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return _part.done
+}
+
+func (d *download) progress(_part *downloadPart, _n int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_part.done += _n
+	d.written += _n
+	if d.options.OnProgress == nil {
+		return
+	}
+
+	contiguous := max(d.options.Offset, 0)
+	for _, part := range d.parts {
+		contiguous = part.start + part.done
+		if part.start+part.done < part.end {
+			break
+		}
+	}
+	d.options.OnProgress(DownloadProgress{
+		Written:    d.written,
+		Contiguous: contiguous,
+		Size:       d.size,
+	})
+}
+
+// contentRangeStart parses the first byte position of "bytes start-end/size"
+func contentRangeStart(_header string) (int64, error) {
+	spec, ok := strings.CutPrefix(_header, "bytes ")
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrBadContentRange, _header)
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrBadContentRange, _header)
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrBadContentRange, _header)
+	}
+	return n, nil
+}
@@ -0,0 +1,168 @@
+package tusc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryWriterAt a growable in memory io.WriterAt and io.ReaderAt
+type memoryWriterAt struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (m *memoryWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if end := int(off) + len(p); end > len(m.buf) {
+		m.buf = append(m.buf, make([]byte, end-len(m.buf))...)
+	}
+	return copy(m.buf[off:], p), nil
+}
+
+func (m *memoryWriterAt) ReadAt(p []byte, off int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return bytes.NewReader(m.buf).ReadAt(p, off)
+}
+
+// newDownloadServer serves _content as a completed upload, with Range support unless _noRange. _truncate cuts the
+// first response short to exercise resuming.
+func newDownloadServer(t *testing.T, _content []byte, _noRange bool, _truncate bool) (*Client, *atomic.Int32) {
+	var gets atomic.Int32
+	var truncated atomic.Bool
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodHead:
+			w.Header().Set("Upload-Offset", strconv.Itoa(len(_content)))
+			w.Header().Set("Upload-Length", strconv.Itoa(len(_content)))
+		case http.MethodGet:
+			gets.Add(1)
+			recorder := httptest.NewRecorder()
+			if _noRange {
+				recorder.Write(_content)
+			} else {
+				http.ServeContent(recorder, r, "", time.Time{}, bytes.NewReader(_content))
+			}
+
+			body := recorder.Body.Bytes()
+			for k, v := range recorder.Header() {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(recorder.Code)
+			if _truncate && truncated.CompareAndSwap(false, true) {
+				// promise more than is sent, the client sees an unexpected EOF
+				body = body[:len(body)/3]
+			}
+			w.Write(body)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	client, err := NewClient(ts.URL+"/files/", nil)
+	assert.Nil(t, err)
+
+	return client, &gets
+}
+
+func TestDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	expected := sha256.Sum256(content)
+
+	for _, tc := range []struct {
+		name     string
+		noRange  bool
+		truncate bool
+		options  *DownloadOptions
+		gets     int32
+	}{
+		{name: "single request", options: nil, gets: 1},
+		{name: "parallel", options: &DownloadOptions{Workers: 4, PartSize: 30000}, gets: 4},
+		{name: "resume offset", options: &DownloadOptions{Offset: 90000, PartSize: 30000}, gets: 1},
+		{name: "no range", noRange: true, options: &DownloadOptions{Workers: 4, PartSize: 30000}, gets: 1},
+		{name: "retry", truncate: true, options: &DownloadOptions{Workers: 2, PartSize: 30000}, gets: 5},
+		{name: "retry no range", noRange: true, truncate: true, options: &DownloadOptions{Offset: 10}, gets: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, gets := newDownloadServer(t, content, tc.noRange, tc.truncate)
+
+			w := &memoryWriterAt{}
+			offset := int64(0)
+			if tc.options != nil {
+				offset = tc.options.Offset
+				// an earlier, interrupted download
+				w.WriteAt(content[:offset], 0)
+			}
+
+			size, err := client.Download(context.Background(), client.BaseUrl+"upload", w, tc.options)
+			assert.Nil(t, err)
+			assert.EqualValues(t, len(content), size)
+			assert.Equal(t, content, w.buf)
+			assert.Equal(t, tc.gets, gets.Load())
+		})
+	}
+
+	client, _ := newDownloadServer(t, content, false, false)
+
+	var last DownloadProgress
+	w := &memoryWriterAt{}
+	_, err := client.Download(context.Background(), client.BaseUrl+"upload", w, &DownloadOptions{
+		Workers:          3,
+		PartSize:         7000,
+		Checksum:         sha256.New,
+		ExpectedChecksum: expected[:],
+		OnProgress: func(_progress DownloadProgress) {
+			assert.LessOrEqual(t, _progress.Contiguous, _progress.Written)
+			last = _progress
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, DownloadProgress{Written: int64(len(content)), Contiguous: int64(len(content)), Size: int64(len(content))}, last)
+
+	_, err = client.Download(context.Background(), client.BaseUrl+"upload", &memoryWriterAt{}, &DownloadOptions{
+		Checksum:         sha256.New,
+		ExpectedChecksum: []byte("nope"),
+	})
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+// writerAtOnly hides ReadAt
+type writerAtOnly struct {
+	w memoryWriterAt
+}
+
+func (w *writerAtOnly) WriteAt(p []byte, off int64) (int, error) {
+	return w.w.WriteAt(p, off)
+}
+
+func TestDownloadErrors(t *testing.T) {
+	client, _ := newDownloadServer(t, []byte("1234"), false, false)
+
+	_, err := client.Download(context.Background(), client.BaseUrl+"upload", &writerAtOnly{}, &DownloadOptions{Checksum: sha256.New})
+	assert.ErrorIs(t, err, ErrChecksumUnverifiable)
+}
+
+func TestContentRangeStart(t *testing.T) {
+	start, err := contentRangeStart("bytes 100-199/1000")
+	assert.Nil(t, err)
+	assert.EqualValues(t, 100, start)
+
+	for _, header := range []string{"", "bytes */1000", "items 1-2/3"} {
+		_, err = contentRangeStart(header)
+		assert.ErrorIs(t, err, ErrBadContentRange, header)
+	}
+}
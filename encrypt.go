@@ -0,0 +1,409 @@
+package tusc
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+const (
+	// EncryptionScheme segmented AEAD, each segment sealed with a nonce of prefix || segment index || final flag
+	EncryptionScheme = "tusc-aead-segmented-v1"
+
+	MetadataEncryption            = "encryption"
+	MetadataEncryptionKeyID       = "encryptionKeyId"
+	MetadataEncryptionNonce       = "encryptionNonce"
+	MetadataEncryptionSegmentSize = "encryptionSegmentSize"
+
+	DefaultEncryptionSegmentSize = 64 * 1024
+
+	// nonce suffix is a 4 byte big endian segment index followed by a 1 byte final segment flag
+	encryptionNonceSuffixSize = 5
+	encryptionMinNonceSize    = 12
+
+	// encryptionRecordKeyPrefix keeps nonce records apart from the upload URLs Config.Store maps fingerprints to
+	encryptionRecordKeyPrefix = "encryption:"
+)
+
+// EncryptionOptions for NewEncryptedUpload
+type EncryptionOptions struct {
+	// KeyID [optional] recorded in metadata so the reader can select the key
+	KeyID string
+	// SegmentSize [optional] plaintext bytes per sealed segment, defaults to DefaultEncryptionSegmentSize
+	SegmentSize int
+	// Nonce [optional] nonce prefix, random if unset. Only reuse a nonce to resume an upload of identical content,
+	//   reusing one with the same key for different content breaks the encryption.
+	Nonce []byte
+	// Store [optional] records the random nonce per fingerprint and key ID, so a later call for the same upload gets
+	//   the same nonce and fingerprint and resumes. Config.Store may be used, keys are prefixed with "encryption:".
+	//   The record is bound to the size and modification time of a file, or a digest of other content, and only
+	//   reused while these match. It is deleted once the upload completes or is terminated with UploadMgr.Terminate.
+	Store Store
+}
+
+// NewEncryptedUpload wraps _upload so content is encrypted before it is sent. Content is sealed in fixed size
+// segments, so any offset can be produced without encrypting what precedes it and uploads resume as usual. The
+// scheme, key ID, nonce and segment size are added to Metadata, other metadata is sent in the clear.
+//
+// Each call picks a random nonce unless EncryptionOptions.Nonce is set or one is recorded in EncryptionOptions.Store.
+// The fingerprint is derived from the original fingerprint and the nonce, so an upload is only ever resumed with the
+// same ciphertext.
+func NewEncryptedUpload(_upload *Upload, _aead cipher.AEAD, _options *EncryptionOptions) (*Upload, error) {
+	if _upload == nil {
+		return nil, ErrNilUpload
+	}
+	if _aead == nil {
+		return nil, ErrNilCipher
+	}
+	if _upload.size < 0 {
+		return nil, ErrDeferredSize
+	}
+	if _aead.NonceSize() < encryptionMinNonceSize {
+		return nil, ErrNonceSize
+	}
+	if _options == nil {
+		_options = &EncryptionOptions{}
+	}
+
+	segmentSize := int64(_options.SegmentSize)
+	if segmentSize <= 0 {
+		segmentSize = DefaultEncryptionSegmentSize
+	}
+
+	var key string
+	if _options.Nonce == nil && _options.Store != nil && _upload.Fingerprint != "" {
+		key = encryptionRecordKey(_upload.Fingerprint, _options.KeyID)
+	}
+
+	prefix, err := encryptionNonce(_upload, key, _aead.NonceSize()-encryptionNonceSuffixSize, _options)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &encryptedStream{
+		source:      _upload.stream,
+		readerAt:    _upload.readerAt,
+		aead:        _aead,
+		prefix:      prefix,
+		segmentSize: segmentSize,
+		plainSize:   _upload.size,
+		cached:      -1,
+	}
+	stream.size = encryptedSize(_upload.size, segmentSize, int64(_aead.Overhead()))
+
+	metadata := make(Metadata, len(_upload.Metadata)+4)
+	for k, v := range _upload.Metadata {
+		metadata[k] = v
+	}
+	metadata[MetadataEncryption] = EncryptionScheme
+	metadata[MetadataEncryptionNonce] = base64.StdEncoding.EncodeToString(prefix)
+	metadata[MetadataEncryptionSegmentSize] = strconv.FormatInt(segmentSize, 10)
+	if _options.KeyID != "" {
+		metadata[MetadataEncryptionKeyID] = _options.KeyID
+	}
+
+	var fingerprint *string
+	if _upload.Fingerprint != "" {
+		hasher := sha256.New()
+		hasher.Write([]byte(_upload.Fingerprint))
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(_options.KeyID))
+		hasher.Write([]byte{0})
+		hasher.Write(prefix)
+		encrypted := hex.EncodeToString(hasher.Sum(nil))
+		fingerprint = &encrypted
+	}
+
+	upload, err := NewUpload(stream, stream.size, metadata, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		upload.release = func() {
+			_options.Store.Delete(key)
+		}
+	}
+
+	return upload, nil
+}
+
+// encryptionRecord is the value recorded in EncryptionOptions.Store
+type encryptionRecord struct {
+	Nonce  []byte `json:"nonce"`
+	Source string `json:"source"`
+}
+
+func encryptionRecordKey(_fingerprint string, _keyID string) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(_fingerprint))
+	hasher.Write([]byte{0})
+	hasher.Write([]byte(_keyID))
+	return encryptionRecordKeyPrefix + hex.EncodeToString(hasher.Sum(nil))
+}
+
+// encryptionNonce returns EncryptionOptions.Nonce, the nonce recorded under _key if it was recorded for the same
+// source, or a new random nonce which is then recorded under _key. An empty _key records nothing.
+func encryptionNonce(_upload *Upload, _key string, _size int, _options *EncryptionOptions) ([]byte, error) {
+	if _options.Nonce != nil {
+		if len(_options.Nonce) != _size {
+			return nil, ErrNonceSize
+		}
+		return _options.Nonce, nil
+	}
+
+	var source string
+	if _key != "" {
+		var err error
+		if source, err = encryptionSource(_upload); err != nil {
+			return nil, err
+		}
+
+		if recorded, ok := _options.Store.Get(_key); ok {
+			var record encryptionRecord
+			if err := json.Unmarshal([]byte(recorded), &record); err == nil && record.Source == source && len(record.Nonce) == _size {
+				return record.Nonce, nil
+			}
+		}
+	}
+
+	prefix := make([]byte, _size)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _key != "" {
+		record, err := json.Marshal(encryptionRecord{Nonce: prefix, Source: source})
+		if err != nil {
+			return nil, err
+		}
+		_options.Store.Set(_key, string(record))
+	}
+
+	return prefix, nil
+}
+
+// encryptionSource identifies the content of _upload, so a recorded nonce is never reused for other content. Files
+// are identified by size and modification time, other sources by a digest of their content.
+func encryptionSource(_upload *Upload) (string, error) {
+	if _upload.file != nil {
+		info, err := _upload.file.Stat()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano()), nil
+	}
+
+	if _, err := _upload.stream.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, _upload.stream); err != nil {
+		return "", err
+	}
+	if _, err := _upload.stream.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func encryptedSize(_plainSize int64, _segmentSize int64, _overhead int64) int64 {
+	// empty content is still one (final) segment
+	segments := max((_plainSize+_segmentSize-1)/_segmentSize, 1)
+	return _plainSize + segments*_overhead
+}
+
+func segmentNonce(_prefix []byte, _index int64, _final bool) []byte {
+	nonce := make([]byte, len(_prefix)+encryptionNonceSuffixSize)
+	copy(nonce, _prefix)
+	binary.BigEndian.PutUint32(nonce[len(_prefix):], uint32(_index))
+	if _final {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// encryptedStream produces ciphertext at any offset by sealing only the segment containing it. The most recent
+// segment is cached as reads are usually much smaller than a segment.
+type encryptedStream struct {
+	source      io.ReadSeeker
+	readerAt    io.ReaderAt
+	aead        cipher.AEAD
+	prefix      []byte
+	segmentSize int64
+	plainSize   int64
+	size        int64
+	position    int64
+
+	mu        sync.Mutex
+	cached    int64
+	cache     []byte
+	plaintext []byte
+}
+
+func (s *encryptedStream) Read(p []byte) (int, error) {
+	n, err := s.ReadAt(p, s.position)
+	s.position += int64(n)
+	if errors.Is(err, io.EOF) && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (s *encryptedStream) Seek(_offset int64, _whence int) (int64, error) {
+	switch _whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		_offset += s.position
+	case io.SeekEnd:
+		_offset += s.size
+	default:
+		return s.position, ErrSeekUnsupported
+	}
+	if _offset < 0 {
+		return s.position, ErrSeekUnsupported
+	}
+
+	s.position = _offset
+	return s.position, nil
+}
+
+// ReadAt is safe for concurrent use if the source upload supported random access
+func (s *encryptedStream) ReadAt(p []byte, _offset int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sealedSize := s.segmentSize + int64(s.aead.Overhead())
+	read := 0
+	for read < len(p) {
+		if _offset >= s.size {
+			return read, io.EOF
+		}
+
+		index := _offset / sealedSize
+		segment, err := s.segment(index)
+		if err != nil {
+			return read, err
+		}
+
+		n := copy(p[read:], segment[_offset-index*sealedSize:])
+		read += n
+		_offset += int64(n)
+	}
+
+	return read, nil
+}
+
+// segment seals segment _index, callers must hold mu
+func (s *encryptedStream) segment(_index int64) ([]byte, error) {
+	if s.cached == _index {
+		return s.cache, nil
+	}
+
+	start := _index * s.segmentSize
+	length := min(s.segmentSize, s.plainSize-start)
+	if cap(s.plaintext) < int(length) {
+		s.plaintext = make([]byte, s.segmentSize)
+	}
+	plaintext := s.plaintext[:length]
+
+	if err := readFullAt(s.source, s.readerAt, plaintext, start, s.plainSize); err != nil {
+		return nil, err
+	}
+
+	final := start+length >= s.plainSize
+	s.cache = s.aead.Seal(s.cache[:0], segmentNonce(s.prefix, _index, final), plaintext, nil)
+	s.cached = _index
+
+	return s.cache, nil
+}
+
+// NewDecryptReader returns a reader decrypting content uploaded with NewEncryptedUpload, _metadata being the
+// upload metadata. Reads fail with an error if any segment has been modified, reordered or the content truncated.
+func NewDecryptReader(_reader io.Reader, _aead cipher.AEAD, _metadata Metadata) (io.Reader, error) {
+	if _aead == nil {
+		return nil, ErrNilCipher
+	}
+	if _metadata[MetadataEncryption] != EncryptionScheme {
+		return nil, ErrEncryptionScheme
+	}
+
+	prefix, err := base64.StdEncoding.DecodeString(_metadata[MetadataEncryptionNonce])
+	if err != nil {
+		return nil, err
+	}
+	if len(prefix) != _aead.NonceSize()-encryptionNonceSuffixSize {
+		return nil, ErrNonceSize
+	}
+
+	segmentSize, err := strconv.ParseInt(_metadata[MetadataEncryptionSegmentSize], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if segmentSize <= 0 {
+		return nil, ErrEncryptionScheme
+	}
+
+	return &decryptReader{
+		reader: bufio.NewReader(_reader),
+		aead:   _aead,
+		prefix: prefix,
+		sealed: make([]byte, segmentSize+int64(_aead.Overhead())),
+	}, nil
+}
+
+type decryptReader struct {
+	reader    *bufio.Reader
+	aead      cipher.AEAD
+	prefix    []byte
+	sealed    []byte
+	plaintext []byte
+	index     int64
+	done      bool
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plaintext) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.plaintext)
+	d.plaintext = d.plaintext[n:]
+	return n, nil
+}
+
+func (d *decryptReader) next() error {
+	n, err := io.ReadFull(d.reader, d.sealed)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		// a short segment can only be the last
+		d.done = true
+	} else if err != nil {
+		return err
+	} else if _, err = d.reader.Peek(1); errors.Is(err, io.EOF) {
+		d.done = true
+	} else if err != nil {
+		return err
+	}
+
+	plaintext, err := d.aead.Open(d.sealed[:0], segmentNonce(d.prefix, d.index, d.done), d.sealed[:n], nil)
+	if err != nil {
+		return err
+	}
+
+	d.plaintext = plaintext
+	d.index++
+	return nil
+}
@@ -0,0 +1,184 @@
+package tusc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAEAD(t *testing.T) cipher.AEAD {
+	block, err := aes.NewCipher(make([]byte, 32))
+	assert.Nil(t, err)
+	aead, err := cipher.NewGCM(block)
+	assert.Nil(t, err)
+	return aead
+}
+
+func newTestEncryptedUpload(t *testing.T, _content []byte) *Upload {
+	fingerprint := "fingerprint-encrypted"
+	upload, err := NewUploadFromBytes(_content, &fingerprint)
+	assert.Nil(t, err)
+
+	encrypted, err := NewEncryptedUpload(upload, newTestAEAD(t), &EncryptionOptions{KeyID: "test", SegmentSize: 16})
+	assert.Nil(t, err)
+	return encrypted
+}
+
+func TestEncryptedUploadRoundTrip(t *testing.T) {
+	aead := newTestAEAD(t)
+
+	for _, size := range []int{0, 1, 16, 32, 100} {
+		content := bytes.Repeat([]byte{'x'}, size)
+		encrypted := newTestEncryptedUpload(t, content)
+		assert.Equal(t, "test", encrypted.Metadata[MetadataEncryptionKeyID])
+
+		ciphertext, err := io.ReadAll(encrypted.stream)
+		assert.Nil(t, err)
+		assert.EqualValues(t, len(ciphertext), encrypted.Size())
+
+		reader, err := NewDecryptReader(bytes.NewReader(ciphertext), aead, encrypted.Metadata)
+		assert.Nil(t, err)
+		plaintext, err := io.ReadAll(reader)
+		assert.Nil(t, err)
+		assert.Equal(t, content, plaintext, "size %d", size)
+	}
+}
+
+func TestEncryptedUploadRandomAccess(t *testing.T) {
+	encrypted := newTestEncryptedUpload(t, bytes.Repeat([]byte("0123456789"), 10))
+
+	ciphertext, err := io.ReadAll(encrypted.stream)
+	assert.Nil(t, err)
+
+	for _, offset := range []int64{70, 3, 33, 0} {
+		_, err = encrypted.stream.Seek(offset, io.SeekStart)
+		assert.Nil(t, err)
+		rest, err := io.ReadAll(encrypted.stream)
+		assert.Nil(t, err)
+		assert.Equal(t, ciphertext[offset:], rest)
+	}
+
+	section, err := io.ReadAll(io.NewSectionReader(encrypted.readerAt, 40, 20))
+	assert.Nil(t, err)
+	assert.Equal(t, ciphertext[40:60], section)
+}
+
+func TestDecryptReaderDetectsTampering(t *testing.T) {
+	aead := newTestAEAD(t)
+	encrypted := newTestEncryptedUpload(t, bytes.Repeat([]byte("0123456789"), 10))
+
+	ciphertext, err := io.ReadAll(encrypted.stream)
+	assert.Nil(t, err)
+
+	modified := bytes.Clone(ciphertext)
+	modified[40] ^= 1
+	reader, err := NewDecryptReader(bytes.NewReader(modified), aead, encrypted.Metadata)
+	assert.Nil(t, err)
+	_, err = io.ReadAll(reader)
+	assert.NotNil(t, err)
+
+	// truncated at a segment boundary
+	sealedSize := 16 + aead.Overhead()
+	reader, err = NewDecryptReader(bytes.NewReader(ciphertext[:2*sealedSize]), aead, encrypted.Metadata)
+	assert.Nil(t, err)
+	_, err = io.ReadAll(reader)
+	assert.NotNil(t, err)
+}
+
+func TestEncryptedUploadFingerprint(t *testing.T) {
+	fingerprint := "fingerprint-TestEncryptedUploadFingerprint"
+	upload, err := NewUploadFromBytes([]byte("1234567890"), &fingerprint)
+	assert.Nil(t, err)
+	aead := newTestAEAD(t)
+
+	a, err := NewEncryptedUpload(upload, aead, nil)
+	assert.Nil(t, err)
+	b, err := NewEncryptedUpload(upload, aead, nil)
+	assert.Nil(t, err)
+	assert.NotEqual(t, a.Fingerprint, b.Fingerprint)
+	assert.NotEqual(t, fingerprint, a.Fingerprint)
+
+	// resuming with the recorded nonce reproduces the upload
+	nonce := a.Metadata[MetadataEncryptionNonce]
+	prefix := make([]byte, aead.NonceSize()-encryptionNonceSuffixSize)
+	copy(prefix, mustDecodeBase64(t, nonce))
+	c, err := NewEncryptedUpload(upload, aead, &EncryptionOptions{Nonce: prefix})
+	assert.Nil(t, err)
+	assert.Equal(t, a.Fingerprint, c.Fingerprint)
+}
+
+func TestEncryptedUploadRecordedNonce(t *testing.T) {
+	fingerprint := "fingerprint-TestEncryptedUploadRecordedNonce"
+	upload, err := NewUploadFromBytes([]byte("1234567890"), &fingerprint)
+	assert.Nil(t, err)
+	aead := newTestAEAD(t)
+	store := NewMemoryStore()
+
+	a, err := NewEncryptedUpload(upload, aead, &EncryptionOptions{Store: store})
+	assert.Nil(t, err)
+	b, err := NewEncryptedUpload(upload, aead, &EncryptionOptions{Store: store})
+	assert.Nil(t, err)
+	assert.Equal(t, a.Fingerprint, b.Fingerprint)
+	assert.Equal(t, a.Metadata[MetadataEncryptionNonce], b.Metadata[MetadataEncryptionNonce])
+	assert.Len(t, store.Keys(), 1)
+
+	// another key never shares the nonce
+	c, err := NewEncryptedUpload(upload, aead, &EncryptionOptions{Store: store, KeyID: "key-2"})
+	assert.Nil(t, err)
+	assert.NotEqual(t, a.Metadata[MetadataEncryptionNonce], c.Metadata[MetadataEncryptionNonce])
+
+	// other content under the same fingerprint never shares the nonce
+	changed, err := NewUploadFromBytes([]byte("0987654321"), &fingerprint)
+	assert.Nil(t, err)
+	d, err := NewEncryptedUpload(changed, aead, &EncryptionOptions{Store: store})
+	assert.Nil(t, err)
+	assert.NotEqual(t, a.Fingerprint, d.Fingerprint)
+	assert.NotEqual(t, a.Metadata[MetadataEncryptionNonce], d.Metadata[MetadataEncryptionNonce])
+	assert.Len(t, store.Keys(), 2)
+
+	d.release()
+	c.release()
+	assert.Empty(t, store.Keys())
+}
+
+func TestEncryptedUploadRecordedNonceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	assert.Nil(t, os.WriteFile(path, []byte("1234567890"), 0o600))
+	aead := newTestAEAD(t)
+	store := NewMemoryStore()
+
+	encrypt := func() *Upload {
+		f, err := os.Open(path)
+		assert.Nil(t, err)
+		t.Cleanup(func() { f.Close() })
+
+		fingerprint := "fingerprint-TestEncryptedUploadRecordedNonceFile"
+		upload, err := NewUploadFromFile(f, &fingerprint)
+		assert.Nil(t, err)
+		encrypted, err := NewEncryptedUpload(upload, aead, &EncryptionOptions{Store: store})
+		assert.Nil(t, err)
+		return encrypted
+	}
+
+	a := encrypt()
+	assert.Equal(t, a.Metadata[MetadataEncryptionNonce], encrypt().Metadata[MetadataEncryptionNonce])
+
+	// a modified file gets a new nonce even though its fingerprint was kept
+	later := time.Now().Add(time.Hour)
+	assert.Nil(t, os.Chtimes(path, later, later))
+	assert.NotEqual(t, a.Metadata[MetadataEncryptionNonce], encrypt().Metadata[MetadataEncryptionNonce])
+}
+
+func mustDecodeBase64(t *testing.T, _s string) []byte {
+	decoded, err := base64.StdEncoding.DecodeString(_s)
+	assert.Nil(t, err)
+	return decoded
+}
@@ -0,0 +1,124 @@
+package tusc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"log/slog"
+)
+
+// EncryptedStore wraps a Store, sealing every value with an AEAD before it is handed to the underlying Store.
+// Upload URLs are capability URLs, so this allows resume state to be kept on shared or untrusted storage.
+// The fingerprint is used as additional data, so a sealed value cannot be moved to another fingerprint.
+// Only values are sealed: fingerprints are stored as plaintext keys so they can be listed and looked up, and
+// may reveal file names, sizes or modification times depending on the Fingerprinter in use.
+type EncryptedStore struct {
+	store Store
+	aead  cipher.AEAD
+}
+
+// NewEncryptedStore returns an *EncryptedStore, which is also a ListableStore whenever _store is one.
+func NewEncryptedStore(_store Store, _aead cipher.AEAD) (*EncryptedStore, error) {
+	if _store == nil {
+		return nil, ErrNilStore
+	}
+	if _aead == nil {
+		return nil, ErrNilCipher
+	}
+
+	return &EncryptedStore{
+		store: _store,
+		aead:  _aead,
+	}, nil
+}
+
+// NewEncryptedStoreFromKey is a convenience wrapper around NewEncryptedStore using AES-GCM.
+// _key must be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+func NewEncryptedStoreFromKey(_store Store, _key []byte) (*EncryptedStore, error) {
+	block, err := aes.NewCipher(_key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEncryptedStore(_store, aead)
+}
+
+func (s *EncryptedStore) Get(fingerprint string) (string, bool) {
+	sealed, ok := s.store.Get(fingerprint)
+	if !ok {
+		return "", false
+	}
+
+	value, err := s.open(fingerprint, sealed)
+	if err != nil {
+		// treat an unreadable record as missing, the upload will simply be recreated
+		slog.Warn("unable to decrypt store record", "fingerprint", fingerprint, "err", err)
+		return "", false
+	}
+
+	return value, true
+}
+
+func (s *EncryptedStore) Set(fingerprint, url string) {
+	sealed, err := s.seal(fingerprint, url)
+	if err != nil {
+		// never fall back to writing the plaintext
+		slog.Warn("unable to encrypt store record", "fingerprint", fingerprint, "err", err)
+		return
+	}
+
+	s.store.Set(fingerprint, sealed)
+}
+
+func (s *EncryptedStore) Delete(fingerprint string) {
+	s.store.Delete(fingerprint)
+}
+
+// Keys returns the fingerprints of the underlying store, or nil if it is not a ListableStore. Fingerprints are
+// not encrypted.
+func (s *EncryptedStore) Keys() []string {
+	if listable, ok := s.store.(ListableStore); ok {
+		return listable.Keys()
+	}
+	return nil
+}
+
+func (s *EncryptedStore) Close() {
+	s.store.Close()
+}
+
+// seal returns base64(nonce || ciphertext) so the result is safe for any string based Store
+func (s *EncryptedStore) seal(_fingerprint, _value string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(_value)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, []byte(_value), []byte(_fingerprint))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *EncryptedStore) open(_fingerprint, _sealed string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(_sealed)
+	if err != nil {
+		return "", err
+	}
+
+	if len(raw) < s.aead.NonceSize() {
+		return "", ErrCiphertextTooShort
+	}
+
+	nonce, ciphertext := raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, []byte(_fingerprint))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
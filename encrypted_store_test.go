@@ -0,0 +1,49 @@
+package tusc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptedStoreRoundTrip(t *testing.T) {
+	backing := NewMemoryStore()
+	store, err := NewEncryptedStoreFromKey(backing, make([]byte, 32))
+	assert.Nil(t, err)
+
+	const url = "https://tus.example.org/files/123"
+	store.Set("fingerprint", url)
+
+	sealed, ok := backing.Get("fingerprint")
+	assert.True(t, ok)
+	assert.NotContains(t, sealed, "tus.example.org")
+
+	value, ok := store.Get("fingerprint")
+	assert.True(t, ok)
+	assert.Equal(t, url, value)
+
+	store.Delete("fingerprint")
+	_, ok = store.Get("fingerprint")
+	assert.False(t, ok)
+}
+
+func TestEncryptedStoreRejectsMovedRecord(t *testing.T) {
+	backing := NewMemoryStore()
+	store, err := NewEncryptedStoreFromKey(backing, make([]byte, 32))
+	assert.Nil(t, err)
+
+	store.Set("a", "https://tus.example.org/files/a")
+	sealed, _ := backing.Get("a")
+	backing.Set("b", sealed)
+
+	_, ok := store.Get("b")
+	assert.False(t, ok)
+}
+
+func TestEncryptedStoreNilArgs(t *testing.T) {
+	_, err := NewEncryptedStore(nil, nil)
+	assert.ErrorIs(t, err, ErrNilStore)
+
+	_, err = NewEncryptedStore(NewMemoryStore(), nil)
+	assert.ErrorIs(t, err, ErrNilCipher)
+}
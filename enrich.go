@@ -0,0 +1,193 @@
+package tusc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MetadataFilename          = "filename"
+	MetadataFiletype          = "filetype"
+	MetadataSize              = "size"
+	MetadataModTime           = "modtime"
+	MetadataRelativePath      = "relativePath"
+	MetadataChecksum          = "checksum"
+	MetadataChecksumAlgorithm = "checksumAlgorithm"
+)
+
+// MetadataEnricher adds metadata to an upload before it is created, see Config.MetadataEnrichers. The built in
+// enrichers never replace a key that is already set, so metadata supplied by the caller wins.
+type MetadataEnricher interface {
+	Enrich(_upload *Upload) error
+}
+
+// MetadataEnricherFunc allows an ordinary function to be used as a MetadataEnricher.
+type MetadataEnricherFunc func(_upload *Upload) error
+
+func (f MetadataEnricherFunc) Enrich(_upload *Upload) error {
+	return f(_upload)
+}
+
+// enrichMetadata runs _enrichers in order, stopping at the first error
+func enrichMetadata(_upload *Upload, _enrichers []MetadataEnricher) error {
+	for _, enricher := range _enrichers {
+		if err := enricher.Enrich(_upload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setMetadataDefault(_upload *Upload, _key string, _value string) {
+	if _upload.Metadata == nil {
+		_upload.Metadata = make(Metadata)
+	}
+	if _, ok := _upload.Metadata[_key]; !ok {
+		_upload.Metadata[_key] = _value
+	}
+}
+
+// FileTypeEnricher sets filetype to the MIME type sniffed from the first 512 bytes of content. Generic results,
+// text/plain and application/octet-stream, give way to the type registered for the filename extension if any.
+// Deferred uploads are skipped as their stream can't be read twice.
+type FileTypeEnricher struct{}
+
+func (e *FileTypeEnricher) Enrich(_upload *Upload) error {
+	if _upload.Deferred() {
+		return nil
+	}
+
+	head := make([]byte, min(512, _upload.size))
+	if err := readFullAt(_upload.stream, _upload.readerAt, head, 0, _upload.size); err != nil {
+		return err
+	}
+	if _, err := _upload.stream.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	filetype := http.DetectContentType(head)
+	if filetype == "application/octet-stream" || strings.HasPrefix(filetype, "text/plain") {
+		if byExtension := mime.TypeByExtension(filepath.Ext(_upload.Metadata[MetadataFilename])); byExtension != "" {
+			filetype = byExtension
+		}
+	}
+
+	setMetadataDefault(_upload, MetadataFiletype, filetype)
+	return nil
+}
+
+// SizeEnricher sets size to the upload length in bytes, deferred uploads are skipped
+type SizeEnricher struct{}
+
+func (e *SizeEnricher) Enrich(_upload *Upload) error {
+	if !_upload.Deferred() {
+		setMetadataDefault(_upload, MetadataSize, strconv.FormatInt(_upload.size, 10))
+	}
+	return nil
+}
+
+// ModTimeEnricher sets modtime to the RFC 3339 modification time of uploads created from files
+type ModTimeEnricher struct{}
+
+func (e *ModTimeEnricher) Enrich(_upload *Upload) error {
+	if _upload.file == nil {
+		return nil
+	}
+
+	fileInfo, err := _upload.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	setMetadataDefault(_upload, MetadataModTime, fileInfo.ModTime().UTC().Format(time.RFC3339))
+	return nil
+}
+
+// RelativePathEnricher sets relativePath to the slash separated path of uploads created from files relative to
+// Root, as Client.UploadDir does. Files outside Root are ErrNotWithinRoot.
+type RelativePathEnricher struct {
+	Root string
+}
+
+func (e *RelativePathEnricher) Enrich(_upload *Upload) error {
+	if _upload.file == nil {
+		return nil
+	}
+
+	root, err := filepath.Abs(e.Root)
+	if err != nil {
+		return err
+	}
+	path, err := filepath.Abs(_upload.file.Name())
+	if err != nil {
+		return err
+	}
+
+	if !isWithin(root, path) {
+		return fmt.Errorf("%w: %s", ErrNotWithinRoot, path)
+	}
+	relative, err := filepath.Rel(root, path)
+	if err != nil {
+		return err
+	}
+
+	setMetadataDefault(_upload, MetadataRelativePath, filepath.ToSlash(relative))
+	return nil
+}
+
+// ChecksumEnricher sets checksum to the hex encoded digest of the whole content and checksumAlgorithm to
+// Algorithm. The content is read an extra time, deferred uploads are skipped.
+type ChecksumEnricher struct {
+	// Hash [optional] defaults to sha256
+	Hash func() hash.Hash
+	// Algorithm name of Hash, sha256 when Hash is unset
+	Algorithm string
+}
+
+func (e *ChecksumEnricher) Enrich(_upload *Upload) error {
+	if _upload.Deferred() {
+		return nil
+	}
+
+	newHash, algorithm := e.Hash, e.Algorithm
+	if newHash == nil {
+		newHash, algorithm = sha256.New, "sha256"
+	}
+
+	hasher := newHash()
+	if _upload.readerAt != nil {
+		if _, err := io.Copy(hasher, io.NewSectionReader(_upload.readerAt, 0, _upload.size)); err != nil {
+			return err
+		}
+	} else {
+		if _, err := _upload.stream.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		n, err := io.Copy(hasher, io.LimitReader(_upload.stream, _upload.size))
+		if err != nil {
+			return err
+		}
+		if n < _upload.size {
+			return shortStreamError(n, _upload.size)
+		}
+		if _, err = _upload.stream.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	// the pair is only set together, a caller supplied checksum keeps its own algorithm
+	if _, ok := _upload.Metadata[MetadataChecksum]; !ok {
+		setMetadataDefault(_upload, MetadataChecksum, hex.EncodeToString(hasher.Sum(nil)))
+		setMetadataDefault(_upload, MetadataChecksumAlgorithm, algorithm)
+	}
+	return nil
+}
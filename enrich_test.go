@@ -0,0 +1,127 @@
+package tusc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileTypeEnricher(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 600))
+	for _, test := range []struct {
+		content  []byte
+		filename string
+		expected string
+	}{
+		{png, "image.bin", "image/png"},
+		{[]byte("%PDF-1.7"), "", "application/pdf"},
+		{[]byte(`{"a": 1}`), "data.json", "application/json"},
+		{[]byte("plain"), "", "text/plain; charset=utf-8"},
+		{[]byte{}, "", "text/plain; charset=utf-8"},
+	} {
+		upload, err := NewUploadFromBytes(test.content, nil)
+		assert.Nil(t, err)
+		if test.filename != "" {
+			upload.Metadata[MetadataFilename] = test.filename
+		}
+
+		assert.Nil(t, (&FileTypeEnricher{}).Enrich(upload))
+		assert.Equal(t, test.expected, upload.Metadata[MetadataFiletype], test.filename)
+	}
+
+	upload, err := NewUploadFromBytes(png, nil)
+	assert.Nil(t, err)
+	upload.Metadata[MetadataFiletype] = "image/x-custom"
+	assert.Nil(t, (&FileTypeEnricher{}).Enrich(upload))
+	assert.Equal(t, "image/x-custom", upload.Metadata[MetadataFiletype])
+}
+
+func TestFileEnrichers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "a.txt")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	assert.Nil(t, os.WriteFile(path, []byte("hello"), 0o644))
+	modTime := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.Nil(t, os.Chtimes(path, modTime, modTime))
+
+	file, err := os.Open(path)
+	assert.Nil(t, err)
+	defer file.Close()
+
+	upload, err := NewUploadFromFile(file, nil)
+	assert.Nil(t, err)
+
+	assert.Nil(t, enrichMetadata(upload, []MetadataEnricher{
+		&SizeEnricher{},
+		&ModTimeEnricher{},
+		&RelativePathEnricher{Root: dir},
+		&ChecksumEnricher{},
+	}))
+
+	sum := sha256.Sum256([]byte("hello"))
+	assert.Equal(t, Metadata{
+		MetadataFilename:          "a.txt",
+		MetadataSize:              "5",
+		MetadataModTime:           "2024-06-01T12:00:00Z",
+		MetadataRelativePath:      "sub/a.txt",
+		MetadataChecksum:          hex.EncodeToString(sum[:]),
+		MetadataChecksumAlgorithm: "sha256",
+	}, upload.Metadata)
+
+	err = (&RelativePathEnricher{Root: filepath.Join(dir, "other")}).Enrich(upload)
+	assert.ErrorIs(t, err, ErrNotWithinRoot)
+}
+
+func TestEnrichersSkipUnsupported(t *testing.T) {
+	upload, err := NewUpload(strings.NewReader("stream"), DeferredSize, nil, nil)
+	assert.Nil(t, err)
+
+	assert.Nil(t, enrichMetadata(upload, []MetadataEnricher{
+		&FileTypeEnricher{},
+		&SizeEnricher{},
+		&ModTimeEnricher{},
+		&RelativePathEnricher{Root: "/"},
+		&ChecksumEnricher{},
+	}))
+	assert.Empty(t, upload.Metadata)
+}
+
+func TestChecksumEnricherStream(t *testing.T) {
+	// not an io.ReaderAt, read by seeking and rewound afterwards
+	upload, err := NewUpload(&readSeekerOnly{strings.NewReader("hello")}, 5, nil, nil)
+	assert.Nil(t, err)
+
+	assert.Nil(t, (&ChecksumEnricher{}).Enrich(upload))
+	sum := sha256.Sum256([]byte("hello"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), upload.Metadata[MetadataChecksum])
+
+	position, err := upload.stream.Seek(0, io.SeekCurrent)
+	assert.Nil(t, err)
+	assert.Zero(t, position)
+
+	upload, err = NewUpload(&readSeekerOnly{strings.NewReader("hel")}, 5, nil, nil)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, (&ChecksumEnricher{}).Enrich(upload), ErrShortStream)
+}
+
+func TestEnrichMetadataStops(t *testing.T) {
+	upload, err := NewUploadFromBytes([]byte("x"), nil)
+	assert.Nil(t, err)
+
+	failed := errors.New("failed")
+	calls := 0
+	err = enrichMetadata(upload, []MetadataEnricher{
+		MetadataEnricherFunc(func(_upload *Upload) error { calls++; return failed }),
+		MetadataEnricherFunc(func(_upload *Upload) error { calls++; return nil }),
+	})
+	assert.ErrorIs(t, err, failed)
+	assert.Equal(t, 1, calls)
+}
@@ -5,16 +5,48 @@ import (
 )
 
 var (
-	ErrChuckSize             = errors.New("chunk size must be greater than zero")
-	ErrNilUpload             = errors.New("upload cannot be nil")
-	ErrLargeUpload           = errors.New("upload is too large")
-	ErrNilStore              = errors.New("store cannot be nil")
-	ErrFingerprintUnset      = errors.New("fingerprint unset")
-	ErrVersionMismatch       = errors.New("protocol version mismatch")
-	ErrOffsetMismatch        = errors.New("upload offset mismatch")
-	ErrUploadNotFound        = errors.New("upload not found")
-	ErrBadMethodOverride     = errors.New("only 'patch' and 'delete' method overriding supported")
-	ErrExtensionNotAvailable = errors.New("extension not available (server)")
-	ErrExtensionNotSupported = errors.New("extension not supported (client)")
-	ErrChecksumSetup         = errors.New("ChecksumAlgName is required when ChecksumAlgFunc is set")
+	ErrChuckSize              = errors.New("chunk size must be greater than zero")
+	ErrNilUpload              = errors.New("upload cannot be nil")
+	ErrLargeUpload            = errors.New("upload is too large")
+	ErrNilStore               = errors.New("store cannot be nil")
+	ErrFingerprintUnset       = errors.New("fingerprint unset")
+	ErrFingerprintUnsupported = errors.New("fingerprinter unsupported for upload")
+	ErrVersionMismatch        = errors.New("protocol version mismatch")
+	ErrOffsetMismatch         = errors.New("upload offset mismatch")
+	ErrUploadNotFound         = errors.New("upload not found")
+	ErrBadMethodOverride      = errors.New("only 'patch' and 'delete' method overriding supported")
+	ErrExtensionNotAvailable  = errors.New("extension not available (server)")
+	ErrExtensionNotSupported  = errors.New("extension not supported (client)")
+	ErrChecksumSetup          = errors.New("ChecksumAlgName is required when ChecksumAlgFunc is set")
+	ErrNilCipher              = errors.New("cipher cannot be nil")
+	ErrCiphertextTooShort     = errors.New("ciphertext too short")
+	ErrShortStream            = errors.New("stream shorter than upload size")
+	ErrBadUploadMode          = errors.New("unknown upload mode")
+	ErrUploadAborted          = errors.New("upload aborted")
+	ErrChunkSizeBounds        = errors.New("min chunk size cannot exceed max chunk size")
+	ErrNilClient              = errors.New("client cannot be nil")
+	ErrBadWorkers             = errors.New("workers must be greater than zero")
+	ErrQueueClosed            = errors.New("queue closed")
+	ErrBadCompression         = errors.New("unknown compression")
+	ErrSourceChanged          = errors.New("source changed since upload was created")
+	ErrSeekUnsupported        = errors.New("seek unsupported")
+	ErrDeferredSize           = errors.New("operation requires a known upload size")
+	ErrNonceSize              = errors.New("unsupported nonce size")
+	ErrEncryptionScheme       = errors.New("unsupported encryption scheme")
+	ErrContentEncoding        = errors.New("unsupported content encoding")
+	ErrBadManifest            = errors.New("invalid manifest")
+	ErrBadWatchAction         = errors.New("unsupported watch action, or WatchMove without MoveTo")
+	ErrUploadIncomplete       = errors.New("upload incomplete")
+	ErrChecksumMismatch       = errors.New("checksum mismatch")
+	ErrChecksumUnverifiable   = errors.New("checksum verification requires a writer implementing io.ReaderAt")
+	ErrBadContentRange        = errors.New("unexpected Content-Range")
+	ErrBadMetadata            = errors.New("invalid Upload-Metadata")
+	ErrMetadataTooLarge       = errors.New("Upload-Metadata too large")
+	ErrNotWithinRoot          = errors.New("file not within root")
+	ErrDigestUnavailable      = errors.New("server reported no digest")
+	ErrNilMiddleware          = errors.New("middleware cannot be nil")
+	ErrUnauthorized           = errors.New("unauthorized")
+	ErrTokenRequest           = errors.New("token request failed")
+	ErrBadSignature           = errors.New("invalid request signature")
+	ErrNilKey                 = errors.New("key cannot be empty")
 )
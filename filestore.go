@@ -0,0 +1,113 @@
+package tusc
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore persists the store as a JSON file so uploads can be resumed across process restarts. Every change is
+// written to a temporary file and renamed over the original, so a crash never leaves a partially written store.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+	m    map[string]string
+}
+
+// NewFileStore opens the store at _path, creating it on first write if it doesn't exist.
+func NewFileStore(_path string) (ListableStore, error) {
+	s := &FileStore{
+		path: _path,
+		m:    make(map[string]string),
+	}
+
+	content, err := os.ReadFile(_path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(content) != 0 {
+		if err = json.Unmarshal(content, &s.m); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Get(fingerprint string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	url, ok := s.m[fingerprint]
+	return url, ok
+}
+
+func (s *FileStore) Set(fingerprint, url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[fingerprint] = url
+	s.save()
+}
+
+func (s *FileStore) Delete(fingerprint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.m[fingerprint]; !ok {
+		return
+	}
+	delete(s.m, fingerprint)
+	s.save()
+}
+
+func (s *FileStore) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedKeys(s.m)
+}
+
+// Close leaves the file in place, unlike MemoryStore the content outlives the process
+func (s *FileStore) Close() {}
+
+// save callers must hold mu
+func (s *FileStore) save() {
+	if err := s.write(); err != nil {
+		// the Store interface has no error, the in memory state stays correct for this process
+		slog.Warn("unable to persist store", "path", s.path, "err", err)
+	}
+}
+
+func (s *FileStore) write() error {
+	content, err := json.MarshalIndent(s.m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,31 @@
+package tusc
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store", "uploads.json")
+
+	store, err := NewFileStore(path)
+	assert.Nil(t, err)
+
+	store.Set("a", "https://tus.example.org/files/a")
+	store.Set("b", "https://tus.example.org/files/b")
+	store.Delete("a")
+	store.Close()
+
+	reopened, err := NewFileStore(path)
+	assert.Nil(t, err)
+
+	_, ok := reopened.Get("a")
+	assert.False(t, ok)
+
+	url, ok := reopened.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, "https://tus.example.org/files/b", url)
+	assert.Equal(t, []string{"b"}, reopened.Keys())
+}
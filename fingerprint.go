@@ -0,0 +1,141 @@
+package tusc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// Fingerprinter derives a stable fingerprint for an upload, used as the Store key when resuming.
+type Fingerprinter interface {
+	Fingerprint(_upload *Upload) (string, error)
+}
+
+// FingerprinterFunc allows an ordinary function to be used as a Fingerprinter.
+type FingerprinterFunc func(_upload *Upload) (string, error)
+
+func (f FingerprinterFunc) Fingerprint(_upload *Upload) (string, error) {
+	return f(_upload)
+}
+
+// ContentHashFingerprinter hashes the upload content. The stream is read in full unless SampleBytes is set, in
+// which case only the first and last SampleBytes of large uploads are hashed along with the size.
+type ContentHashFingerprinter struct {
+	// Hash [optional] constructor for the hash to use, defaults to sha256
+	Hash func() hash.Hash
+	// SampleBytes [optional] bytes to hash from each of the head and tail, 0 hashes the whole stream
+	SampleBytes int64
+}
+
+func (f *ContentHashFingerprinter) Fingerprint(_upload *Upload) (string, error) {
+	if _upload == nil {
+		return "", ErrNilUpload
+	}
+
+	h := sha256.New
+	if f.Hash != nil {
+		h = f.Hash
+	}
+	hasher := h()
+
+	// the stream position belongs to the upload manager, so always rewind before returning
+	defer _upload.stream.Seek(0, io.SeekStart)
+
+	if f.SampleBytes <= 0 || _upload.size <= 2*f.SampleBytes {
+		if _, err := _upload.stream.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(hasher, _upload.stream); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(hasher.Sum(nil)), nil
+	}
+
+	for _, offset := range []int64{0, _upload.size - f.SampleBytes} {
+		if _, err := _upload.stream.Seek(offset, io.SeekStart); err != nil {
+			return "", err
+		}
+		if _, err := io.CopyN(hasher, _upload.stream, f.SampleBytes); err != nil {
+			return "", err
+		}
+	}
+	hasher.Write([]byte(strconv.FormatInt(_upload.size, 10)))
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// FileInfoFingerprinter fingerprints uploads created from files using absolute path, size, mtime and inode.
+// It is cheap as the content is never read, but any rename or touch produces a new fingerprint.
+type FileInfoFingerprinter struct{}
+
+func (f *FileInfoFingerprinter) Fingerprint(_upload *Upload) (string, error) {
+	if _upload == nil {
+		return "", ErrNilUpload
+	}
+	if _upload.file == nil {
+		return "", ErrFingerprintUnsupported
+	}
+
+	fileInfo, err := _upload.file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	path, err := filepath.Abs(_upload.file.Name())
+	if err != nil {
+		return "", err
+	}
+
+	hasher := sha256.New()
+	hasher.Write([]byte(path))
+	hasher.Write([]byte{0})
+	hasher.Write([]byte(strconv.FormatInt(fileInfo.Size(), 10)))
+	hasher.Write([]byte{0})
+	hasher.Write([]byte(strconv.FormatInt(fileInfo.ModTime().UnixNano(), 10)))
+	if inode, ok := fileInode(fileInfo); ok {
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(strconv.FormatUint(inode, 10)))
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// MetadataFingerprinter fingerprints uploads using their metadata and size. If Keys is set only those keys are
+// used, otherwise all metadata is used.
+type MetadataFingerprinter struct {
+	Keys []string
+}
+
+func (f *MetadataFingerprinter) Fingerprint(_upload *Upload) (string, error) {
+	if _upload == nil {
+		return "", ErrNilUpload
+	}
+
+	keys := f.Keys
+	if len(keys) == 0 {
+		for k := range _upload.Metadata {
+			keys = append(keys, k)
+		}
+	}
+	keys = slices.Clone(keys)
+	slices.Sort(keys)
+
+	hasher := sha256.New()
+	hasher.Write([]byte(strconv.FormatInt(_upload.size, 10)))
+	for _, k := range keys {
+		v, ok := _upload.Metadata[k]
+		if !ok {
+			return "", ErrFingerprintUnsupported
+		}
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(k))
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(v))
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
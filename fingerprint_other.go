@@ -0,0 +1,11 @@
+//go:build !unix
+
+package tusc
+
+import (
+	"os"
+)
+
+func fileInode(_fileInfo os.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,77 @@
+package tusc
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentHashFingerprinter(t *testing.T) {
+	a, err := NewUploadFromBytesWithFingerprinter([]byte("1234567890"), &ContentHashFingerprinter{})
+	assert.Nil(t, err)
+	b, err := NewUploadFromBytesWithFingerprinter([]byte("1234567890"), &ContentHashFingerprinter{})
+	assert.Nil(t, err)
+	c, err := NewUploadFromBytesWithFingerprinter([]byte("0987654321"), &ContentHashFingerprinter{})
+	assert.Nil(t, err)
+
+	assert.NotEmpty(t, a.Fingerprint)
+	assert.Equal(t, a.Fingerprint, b.Fingerprint)
+	assert.NotEqual(t, a.Fingerprint, c.Fingerprint)
+}
+
+func TestContentHashFingerprinterSampled(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), 1024)
+	changed := bytes.Clone(content)
+	changed[512] = 'b'
+
+	fingerprinter := &ContentHashFingerprinter{SampleBytes: 64}
+	a, err := NewUploadFromBytesWithFingerprinter(content, fingerprinter)
+	assert.Nil(t, err)
+	b, err := NewUploadFromBytesWithFingerprinter(changed, fingerprinter)
+	assert.Nil(t, err)
+
+	// the middle of the stream is not sampled
+	assert.Equal(t, a.Fingerprint, b.Fingerprint)
+}
+
+func TestFileInfoFingerprinter(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	assert.Nil(t, err)
+	defer f.Close()
+
+	a, err := NewUploadFromFileWithFingerprinter(f, &FileInfoFingerprinter{})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, a.Fingerprint)
+
+	_, err = f.Write([]byte("grow"))
+	assert.Nil(t, err)
+
+	b, err := NewUploadFromFileWithFingerprinter(f, &FileInfoFingerprinter{})
+	assert.Nil(t, err)
+	assert.NotEqual(t, a.Fingerprint, b.Fingerprint)
+
+	_, err = NewUploadFromBytesWithFingerprinter([]byte("1"), &FileInfoFingerprinter{})
+	assert.ErrorIs(t, err, ErrFingerprintUnsupported)
+}
+
+func TestMetadataFingerprinter(t *testing.T) {
+	u, err := NewUploadFromBytes([]byte("1234567890"), nil)
+	assert.Nil(t, err)
+	u.Metadata["filename"] = "foobar.txt"
+	u.Metadata["other"] = "ignored"
+
+	err = u.GenerateFingerprint(&MetadataFingerprinter{Keys: []string{"filename"}})
+	assert.Nil(t, err)
+	first := u.Fingerprint
+
+	u.Metadata["other"] = "changed"
+	err = u.GenerateFingerprint(&MetadataFingerprinter{Keys: []string{"filename"}})
+	assert.Nil(t, err)
+	assert.Equal(t, first, u.Fingerprint)
+
+	err = u.GenerateFingerprint(&MetadataFingerprinter{Keys: []string{"missing"}})
+	assert.ErrorIs(t, err, ErrFingerprintUnsupported)
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package tusc
+
+import (
+	"os"
+	"syscall"
+)
+
+func fileInode(_fileInfo os.FileInfo) (uint64, bool) {
+	stat, ok := _fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Ino), true
+}
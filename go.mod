@@ -3,6 +3,7 @@ module github.com/offby0x01/tusc
 go 1.22.5
 
 require (
+	github.com/klauspost/compress v1.17.11
 	github.com/stretchr/testify v1.9.0
 	github.com/tus/tusd v1.13.0
 )
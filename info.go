@@ -0,0 +1,84 @@
+package tusc
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UploadConcat the role of an upload in the concatenation extension
+type UploadConcat string
+
+const (
+	UploadConcatNone    UploadConcat = ""
+	UploadConcatPartial UploadConcat = "partial"
+	UploadConcatFinal   UploadConcat = "final"
+)
+
+// UploadInfo the state of an upload as reported by HEAD
+type UploadInfo struct {
+	URL string
+	// Offset 0 for a final upload whose partial uploads are still in progress, servers omit it until then
+	Offset int64
+	// Length DeferredSize while the length is deferred
+	Length   int64
+	Metadata Metadata
+	Concat   UploadConcat
+	// ConcatParts URLs of the partial uploads making up a final upload
+	ConcatParts []string
+	// Expires zero unless the server reports an expiry
+	Expires time.Time
+}
+
+// Deferred reports whether the upload length is still unknown
+func (i *UploadInfo) Deferred() bool {
+	return i.Length < 0
+}
+
+// Complete reports whether the server has every byte
+func (i *UploadInfo) Complete() bool {
+	return i.Length >= 0 && i.Offset >= i.Length
+}
+
+func parseUploadInfo(_url string, _header http.Header) (*UploadInfo, error) {
+	var err error
+	info := &UploadInfo{URL: _url, Length: DeferredSize}
+
+	// a final upload may omit the length until every partial upload completes
+	if length := _header.Get("Upload-Length"); length != "" {
+		if info.Length, err = strconv.ParseInt(length, 10, 64); err != nil {
+			return nil, fmt.Errorf("bad Upload-Length: %w", err)
+		}
+	}
+	if info.Metadata, err = DecodeMetadata(_header.Get("Upload-Metadata")); err != nil {
+		return nil, err
+	}
+	if expires := _header.Get("Upload-Expires"); expires != "" {
+		if info.Expires, err = http.ParseTime(expires); err != nil {
+			return nil, fmt.Errorf("bad Upload-Expires: %w", err)
+		}
+	}
+
+	concat := _header.Get("Upload-Concat")
+	switch {
+	case concat == "":
+	case concat == string(UploadConcatPartial):
+		info.Concat = UploadConcatPartial
+	case strings.HasPrefix(concat, string(UploadConcatFinal)+";"):
+		info.Concat = UploadConcatFinal
+		info.ConcatParts = strings.Fields(concat[len(UploadConcatFinal)+1:])
+	default:
+		return nil, fmt.Errorf("bad Upload-Concat: %q", concat)
+	}
+
+	// an unfinished final upload has no offset yet
+	if offset := _header.Get("Upload-Offset"); offset != "" || info.Concat != UploadConcatFinal {
+		if info.Offset, err = strconv.ParseInt(offset, 10, 64); err != nil {
+			return nil, fmt.Errorf("bad Upload-Offset: %w", err)
+		}
+	}
+
+	return info, nil
+}
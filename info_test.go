@@ -0,0 +1,73 @@
+package tusc
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUploadInfo(t *testing.T) {
+	header := http.Header{}
+	header.Set("Upload-Offset", "5")
+	header.Set("Upload-Length", "10")
+	header.Set("Upload-Metadata", "filename ZGF0YS5iaW4=")
+	header.Set("Upload-Expires", "Wed, 25 Jun 2014 16:00:00 GMT")
+
+	info, err := parseUploadInfo("http://example.com/files/a", header)
+	assert.Nil(t, err)
+	assert.Equal(t, "http://example.com/files/a", info.URL)
+	assert.EqualValues(t, 5, info.Offset)
+	assert.EqualValues(t, 10, info.Length)
+	assert.False(t, info.Deferred())
+	assert.False(t, info.Complete())
+	assert.Equal(t, Metadata{"filename": "data.bin"}, info.Metadata)
+	assert.True(t, info.Expires.Equal(time.Date(2014, 6, 25, 16, 0, 0, 0, time.UTC)))
+	assert.Equal(t, UploadConcatNone, info.Concat)
+
+	header = http.Header{}
+	header.Set("Upload-Offset", "0")
+	header.Set("Upload-Defer-Length", "1")
+	header.Set("Upload-Concat", "partial")
+	info, err = parseUploadInfo("http://example.com/files/b", header)
+	assert.Nil(t, err)
+	assert.True(t, info.Deferred())
+	assert.False(t, info.Complete())
+	assert.True(t, info.Expires.IsZero())
+	assert.Equal(t, UploadConcatPartial, info.Concat)
+
+	header = http.Header{}
+	header.Set("Upload-Offset", "0")
+	header.Set("Upload-Concat", "final;http://example.com/files/a /files/b")
+	info, err = parseUploadInfo("http://example.com/files/c", header)
+	assert.Nil(t, err)
+	assert.Equal(t, UploadConcatFinal, info.Concat)
+	assert.Equal(t, []string{"http://example.com/files/a", "/files/b"}, info.ConcatParts)
+
+	// unfinished final upload without an offset
+	header.Del("Upload-Offset")
+	info, err = parseUploadInfo("http://example.com/files/c", header)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, info.Offset)
+	assert.True(t, info.Deferred())
+	assert.False(t, info.Complete())
+
+	// any other upload must report its offset
+	_, err = parseUploadInfo("http://example.com/files/d", http.Header{})
+	assert.Error(t, err)
+
+	for name, value := range map[string]string{
+		"Upload-Offset":   "x",
+		"Upload-Length":   "-",
+		"Upload-Expires":  "tomorrow",
+		"Upload-Concat":   "final",
+		"Upload-Metadata": "name !!!",
+	} {
+		header = http.Header{}
+		header.Set("Upload-Offset", "0")
+		header.Set(name, value)
+		_, err = parseUploadInfo("http://example.com/files/d", header)
+		assert.Error(t, err, name)
+	}
+}
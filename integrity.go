@@ -0,0 +1,292 @@
+package tusc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// IntegritySource where the digest an upload was checked against came from
+type IntegritySource string
+
+const (
+	// IntegritySourceMetadata the checksum metadata the upload was created with
+	IntegritySourceMetadata IntegritySource = "metadata"
+	// IntegritySourceServer a digest reported by the server once the upload completed
+	IntegritySourceServer IntegritySource = "server"
+)
+
+// IntegrityOptions verify an upload as a whole once complete, see Config.Integrity. A digest of the content is
+// computed from the bytes sent while uploading and compared with the checksum metadata, when it uses the same
+// algorithm, and with the digest the server reports in Header if set. Content sent before a resume is read from the
+// source again, so sources that can't seek back can only be verified when uploaded without interruption.
+type IntegrityOptions struct {
+	// Hash [optional] defaults to sha256
+	Hash func() hash.Hash
+	// Algorithm name of Hash, sha256 when Hash is unset
+	Algorithm string
+	// DeclareInMetadata sends the digest as checksum and checksumAlgorithm metadata at creation, so server hooks can
+	// verify it too. The content is read once more before creating, deferred uploads can't declare a digest.
+	DeclareInMetadata bool
+	// Header [optional] response header of a HEAD request carrying the server computed digest, hex or base64,
+	// optionally prefixed by the algorithm name as in Upload-Checksum
+	Header string
+	// Endpoint [optional] URL of the HEAD request for Header given the upload URL, defaults to the upload URL
+	Endpoint func(_url string) string
+}
+
+func (o *IntegrityOptions) hash() (func() hash.Hash, string) {
+	if o.Hash == nil {
+		return sha256.New, "sha256"
+	}
+	return o.Hash, o.Algorithm
+}
+
+// IntegrityError an upload whose content doesn't match the digest it was checked against. Matches
+// ErrChecksumMismatch.
+type IntegrityError struct {
+	URL       string
+	Source    IntegritySource
+	Algorithm string
+	// Expected hex digest declared in metadata or reported by the server
+	Expected string
+	// Actual hex digest of the content sent
+	Actual string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("%v: %s: %s %s digest %s, uploaded content %s", ErrChecksumMismatch, e.URL, e.Source,
+		e.Algorithm, e.Expected, e.Actual)
+}
+
+func (e *IntegrityError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+// uploadDigest hashes the content of an upload as it is sent. Only a prefix is ever hashed: a request starting
+// where hashing got to is teed into the hash, and rolled back unless the server confirms every byte read. Whatever
+// isn't hashed while sending, after a resume or failure, is read from the source once the upload completes.
+type uploadDigest struct {
+	options   *IntegrityOptions
+	algorithm string
+	hash      hash.Hash
+	// position bytes hashed
+	position int64
+}
+
+func newUploadDigest(_options *IntegrityOptions) *uploadDigest {
+	newHash, algorithm := _options.hash()
+	return &uploadDigest{
+		options:   _options,
+		algorithm: algorithm,
+		hash:      newHash(),
+	}
+}
+
+// tee returns _reader hashing what is read when _offset is where hashing got to, along with a function to call
+// with the server offset once the request ends
+func (d *uploadDigest) tee(_reader io.Reader, _offset int64) (io.Reader, func(_confirmed int64)) {
+	noop := func(int64) {}
+	if d == nil || _offset != d.position {
+		return _reader, noop
+	}
+
+	// rolling back needs the hash state, hashes that can't export it are computed from the source at the end
+	marshaler, ok := d.hash.(encoding.BinaryMarshaler)
+	if !ok {
+		return _reader, noop
+	}
+	snapshot, err := marshaler.MarshalBinary()
+	if err != nil {
+		return _reader, noop
+	}
+
+	tee := &digestReader{reader: _reader, hash: d.hash}
+	return tee, func(_confirmed int64) {
+		written := tee.stop()
+		if _confirmed == _offset+written {
+			d.position = _confirmed
+		} else if err := d.hash.(encoding.BinaryUnmarshaler).UnmarshalBinary(snapshot); err != nil {
+			// unreachable for the standard library hashes, start over from the source
+			d.hash.Reset()
+			d.position = 0
+		}
+	}
+}
+
+// sum hashes whatever of _upload wasn't hashed while sending and returns the digest
+func (d *uploadDigest) sum(_upload *Upload) ([]byte, error) {
+	remaining := _upload.size - d.position
+	if remaining > 0 {
+		var source io.Reader
+		if _upload.readerAt != nil {
+			source = io.NewSectionReader(_upload.readerAt, d.position, remaining)
+		} else {
+			if _, err := _upload.stream.Seek(d.position, io.SeekStart); err != nil {
+				return nil, err
+			}
+			source = io.LimitReader(_upload.stream, remaining)
+		}
+
+		n, err := io.Copy(d.hash, source)
+		d.position += n
+		if err != nil {
+			return nil, err
+		}
+		if d.position < _upload.size {
+			return nil, shortStreamError(d.position, _upload.size)
+		}
+	}
+
+	return d.hash.Sum(nil), nil
+}
+
+// digestReader writes what is read into hash until stopped, the transport may read the body after the request
+// has ended
+type digestReader struct {
+	reader io.Reader
+
+	mu      sync.Mutex
+	hash    hash.Hash
+	written int64
+	stopped bool
+}
+
+func (r *digestReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+
+	r.mu.Lock()
+	if !r.stopped && n > 0 {
+		r.hash.Write(p[:n])
+		r.written += int64(n)
+	}
+	r.mu.Unlock()
+
+	return n, err
+}
+
+func (r *digestReader) Close() error {
+	if closer, ok := r.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (r *digestReader) stop() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+	return r.written
+}
+
+// SetIntegrity overrides Config.Integrity for this upload, nil disables verification. Must be called before Upload.
+func (um *UploadMgr) SetIntegrity(_options *IntegrityOptions) {
+	um.digest = nil
+	if _options != nil {
+		um.digest = newUploadDigest(_options)
+	}
+}
+
+// verifyIntegrity compares the digest of the completed upload with its checksum metadata and the server's digest
+func (um *UploadMgr) verifyIntegrity() error {
+	if um.digest == nil {
+		return nil
+	}
+
+	sum, err := um.digest.sum(um.upload)
+	if err != nil {
+		return fmt.Errorf("integrity: %w", err)
+	}
+	actual := hex.EncodeToString(sum)
+
+	if um.upload.Metadata[MetadataChecksumAlgorithm] == um.digest.algorithm {
+		if expected := um.upload.Metadata[MetadataChecksum]; expected != "" && !strings.EqualFold(expected, actual) {
+			return um.integrityError(IntegritySourceMetadata, expected, actual)
+		}
+	}
+
+	if um.digest.options.Header == "" {
+		return nil
+	}
+
+	reported, err := um.client.reportedDigest(um.url, um.digest.options)
+	if err != nil {
+		return fmt.Errorf("integrity: %w", err)
+	}
+	expected, err := decodeDigest(reported, um.digest.algorithm)
+	if err != nil {
+		return fmt.Errorf("integrity: %s: %w", um.digest.options.Header, err)
+	}
+	if !bytes.Equal(expected, sum) {
+		return um.integrityError(IntegritySourceServer, hex.EncodeToString(expected), actual)
+	}
+
+	return nil
+}
+
+func (um *UploadMgr) integrityError(_source IntegritySource, _expected string, _actual string) error {
+	return &IntegrityError{
+		URL:       um.url,
+		Source:    _source,
+		Algorithm: um.digest.algorithm,
+		Expected:  _expected,
+		Actual:    _actual,
+	}
+}
+
+// reportedDigest fetches the digest the server computed for the upload at _url
+func (c *Client) reportedDigest(_url string, _options *IntegrityOptions) (string, error) {
+	endpoint := _url
+	if _options.Endpoint != nil {
+		endpoint = _options.Endpoint(_url)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodHead, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", newClientError(res)
+	}
+
+	digest := res.Header.Get(_options.Header)
+	if digest == "" {
+		return "", fmt.Errorf("%w: no %s header", ErrDigestUnavailable, _options.Header)
+	}
+	return digest, nil
+}
+
+// decodeDigest parses a hex or base64 digest, optionally prefixed by the algorithm name
+func decodeDigest(_digest string, _algorithm string) ([]byte, error) {
+	if algorithm, digest, ok := strings.Cut(strings.TrimSpace(_digest), " "); ok {
+		if !strings.EqualFold(algorithm, _algorithm) {
+			return nil, fmt.Errorf("%w: algorithm %q, expected %q", ErrDigestUnavailable, algorithm, _algorithm)
+		}
+		_digest = digest
+	}
+	_digest = strings.TrimSpace(_digest)
+
+	if decoded, err := hex.DecodeString(_digest); err == nil {
+		return decoded, nil
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(_digest); err == nil {
+		return decoded, nil
+	}
+	return nil, fmt.Errorf("%w: %q is neither hex nor base64", ErrDigestUnavailable, _digest)
+}
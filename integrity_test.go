@@ -0,0 +1,150 @@
+package tusc
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeDigest(t *testing.T) {
+	sum := sha256.Sum256([]byte("hello"))
+	for _, digest := range []string{
+		hex.EncodeToString(sum[:]),
+		strings.ToUpper(hex.EncodeToString(sum[:])),
+		base64.StdEncoding.EncodeToString(sum[:]),
+		"sha256 " + base64.StdEncoding.EncodeToString(sum[:]),
+		" SHA256 " + hex.EncodeToString(sum[:]) + " ",
+	} {
+		decoded, err := decodeDigest(digest, "sha256")
+		assert.Nil(t, err, digest)
+		assert.Equal(t, sum[:], decoded, digest)
+	}
+
+	for _, digest := range []string{"md5 " + hex.EncodeToString(sum[:]), "not a digest", "!!"} {
+		_, err := decodeDigest(digest, "sha256")
+		assert.ErrorIs(t, err, ErrDigestUnavailable, digest)
+	}
+}
+
+func TestUploadDigest(t *testing.T) {
+	content := []byte("0123456789")
+	upload, err := NewUploadFromBytes(content, nil)
+	assert.Nil(t, err)
+
+	digest := newUploadDigest(&IntegrityOptions{})
+
+	// confirmed requests move the hashed prefix on
+	reader, confirm := digest.tee(strings.NewReader("0123"), 0)
+	_, err = io.ReadAll(reader)
+	assert.Nil(t, err)
+	confirm(4)
+	assert.EqualValues(t, 4, digest.position)
+
+	// a request the server only partly took is rolled back
+	reader, confirm = digest.tee(strings.NewReader("4567"), 4)
+	_, err = io.ReadAll(reader)
+	assert.Nil(t, err)
+	confirm(6)
+	assert.EqualValues(t, 4, digest.position)
+
+	// requests after a gap aren't hashed
+	reader, confirm = digest.tee(strings.NewReader("6789"), 6)
+	_, err = io.ReadAll(reader)
+	assert.Nil(t, err)
+	confirm(10)
+	assert.EqualValues(t, 4, digest.position)
+
+	// the rest is read from the source
+	sum, err := digest.sum(upload)
+	assert.Nil(t, err)
+	expected := sha256.Sum256(content)
+	assert.Equal(t, expected[:], sum)
+}
+
+func TestUploadDigestWithoutMarshaler(t *testing.T) {
+	content := []byte("0123456789")
+	upload, err := NewUploadFromBytes(content, nil)
+	assert.Nil(t, err)
+
+	digest := newUploadDigest(&IntegrityOptions{Hash: func() hash.Hash { return plainHash{md5.New()} }, Algorithm: "md5"})
+	reader, confirm := digest.tee(strings.NewReader("0123456789"), 0)
+	_, err = io.ReadAll(reader)
+	assert.Nil(t, err)
+	confirm(10)
+	assert.Zero(t, digest.position)
+
+	sum, err := digest.sum(upload)
+	assert.Nil(t, err)
+	expected := md5.Sum(content)
+	assert.Equal(t, expected[:], sum)
+}
+
+// plainHash hides the binary marshaling of the wrapped hash
+type plainHash struct {
+	hash.Hash
+}
+
+func TestVerifyIntegrityServerDigest(t *testing.T) {
+	content := []byte("0123456789")
+	sum := sha256.Sum256(content)
+	reported := hex.EncodeToString(sum[:])
+
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			path = r.URL.Path
+			if reported != "" {
+				w.Header().Set("X-Digest", reported)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL+"/files/", nil)
+	assert.Nil(t, err)
+
+	verify := func(_options *IntegrityOptions) error {
+		upload, err := NewUploadFromBytes(content, nil)
+		assert.Nil(t, err)
+		// already complete, so Upload only verifies
+		uploadMgr, err := NewUploadMgr(client, server.URL+"/files/a", upload, int64(len(content)))
+		assert.Nil(t, err)
+		uploadMgr.SetIntegrity(_options)
+		return uploadMgr.Upload()
+	}
+
+	assert.Nil(t, verify(&IntegrityOptions{Header: "X-Digest"}))
+	assert.Equal(t, "/files/a", path)
+
+	assert.Nil(t, verify(&IntegrityOptions{Header: "X-Digest", Endpoint: func(_url string) string {
+		return _url + "/digest"
+	}}))
+	assert.Equal(t, "/files/a/digest", path)
+
+	reported = strings.Repeat("00", sha256.Size)
+	err = verify(&IntegrityOptions{Header: "X-Digest"})
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	var integrityErr *IntegrityError
+	if assert.True(t, errors.As(err, &integrityErr)) {
+		assert.Equal(t, IntegritySourceServer, integrityErr.Source)
+		assert.Equal(t, reported, integrityErr.Expected)
+		assert.Equal(t, hex.EncodeToString(sum[:]), integrityErr.Actual)
+	}
+
+	reported = ""
+	assert.ErrorIs(t, verify(&IntegrityOptions{Header: "X-Digest"}), ErrDigestUnavailable)
+
+	// disabled
+	assert.Nil(t, verify(nil))
+}
@@ -2,117 +2,373 @@ package tusc
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+type UploadMode int
+
+const (
+	// UploadModeChunked sends one PATCH per Config.ChunkSizeBytes
+	UploadModeChunked UploadMode = iota
+	// UploadModeStreaming sends the remainder of the upload in a single streaming PATCH
+	UploadModeStreaming
+	// UploadModeAdaptive sends one PATCH per chunk, resizing chunks based on measured throughput and failures
+	UploadModeAdaptive
 )
 
 type UploadMgr struct {
-	client     *Client
-	url        string
-	upload     *Upload
-	offset     int64
-	aborted    bool
-	uploadSubs []chan Upload
-	notifyChan chan bool
+	client      *Client
+	url         string
+	upload      *Upload
+	offset      int64
+	mode        UploadMode
+	rateLimiter *RateLimiter
+	aborted     atomic.Bool
+	ctx         context.Context
+	cancelMu    sync.Mutex
+	cancel      context.CancelCauseFunc
+	subsMu      sync.Mutex
+	uploadSubs  []chan Upload
+	digest      *uploadDigest
 }
 
 func NewUploadMgr(_client *Client, _url string, _upload *Upload, _offset int64) (*UploadMgr, error) {
-	notifyChan := make(chan bool)
-
 	uploadMgr := &UploadMgr{
-		client:     _client,
-		url:        _url,
-		upload:     _upload,
-		offset:     _offset,
-		aborted:    false,
-		uploadSubs: nil,
-		notifyChan: notifyChan,
+		client:      _client,
+		url:         _url,
+		upload:      _upload,
+		offset:      _offset,
+		mode:        _client.Config.UploadMode,
+		rateLimiter: nil,
+		ctx:         context.Background(),
+		uploadSubs:  nil,
 	}
 
-	go uploadMgr.broadcast()
+	if _client.Config.Integrity != nil {
+		uploadMgr.digest = newUploadDigest(_client.Config.Integrity)
+	}
 
 	return uploadMgr, nil
 }
 
-func (um *UploadMgr) broadcast() {
-	for range um.notifyChan {
-		for _, c := range um.uploadSubs {
-			c <- *um.upload
-		}
+// publish records _offset on the upload and sends a copy to every subscriber, blocking until each has received it.
+// It must only be called from the goroutine running Upload, so the copy is never taken while the offset is being
+// written, and nothing is sent once Upload has returned.
+func (um *UploadMgr) publish(_offset int64) {
+	um.upload.setOffset(_offset)
+	upload := *um.upload
+
+	um.subsMu.Lock()
+	subs := um.uploadSubs
+	um.subsMu.Unlock()
+
+	for _, c := range subs {
+		c <- upload
 	}
 }
 
+// URL of the upload on the server
+func (um *UploadMgr) URL() string {
+	return um.url
+}
+
+// Abort stops the upload, interrupting the request in flight. Upload returns without an error.
 func (um *UploadMgr) Abort() {
-	um.aborted = true
+	um.aborted.Store(true)
+
+	um.cancelMu.Lock()
+	defer um.cancelMu.Unlock()
+	if um.cancel != nil {
+		um.cancel(ErrUploadAborted)
+	}
 }
 
+// begin gives the Upload call its own context, so Abort interrupts requests and rate limited waits in flight. ctx is
+// only used by the goroutine running Upload, cancelMu guards cancel which Abort calls from any goroutine.
+func (um *UploadMgr) begin() {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	um.cancelMu.Lock()
+	um.ctx, um.cancel = ctx, cancel
+	um.cancelMu.Unlock()
+
+	// Abort may have been called before the context existed
+	if um.aborted.Load() {
+		cancel(ErrUploadAborted)
+	}
+}
+
+// end releases the context of the Upload call
+func (um *UploadMgr) end() {
+	um.cancelMu.Lock()
+	defer um.cancelMu.Unlock()
+	um.cancel(nil)
+	um.ctx, um.cancel = context.Background(), nil
+}
+
+// SetRateLimiter limits this upload in addition to Config.RateLimiter, so the lower of both rates applies. nil
+// removes the per upload limit. The limiter may be adjusted with RateLimiter.SetRate while uploading.
+func (um *UploadMgr) SetRateLimiter(_rateLimiter *RateLimiter) {
+	um.rateLimiter = _rateLimiter
+}
+
+// throttle applies the per upload limiter and the client wide limiter to PATCH bodies
+func (um *UploadMgr) throttle(_ctx context.Context, _reader io.Reader) io.Reader {
+	return newThrottledReader(_ctx, newThrottledReader(_ctx, _reader, um.rateLimiter), um.client.Config.RateLimiter)
+}
+
+// SetMode overrides Config.UploadMode for this upload
+func (um *UploadMgr) SetMode(_mode UploadMode) {
+	um.mode = _mode
+}
+
+// Upload sends the upload until complete or aborted, then verifies it if Config.Integrity or SetIntegrity is set
 func (um *UploadMgr) Upload() error {
+	um.begin()
+	defer um.end()
+
 	// if uploading a file that has already been uploaded, below loop would be skipped
 	//   and channel would never be notified that it is (already) completed. This ensures
 	//   the manager is always notified of a success.
-	if um.upload.size == um.offset {
-		um.upload.setOffset(um.offset)
-		um.notifyChan <- true
-		return nil
+	if um.complete() {
+		um.publish(um.offset)
+		um.release()
+		return um.verifyIntegrity()
 	}
 
-	for um.offset < um.upload.size && !um.aborted {
-		err := um.UploadChunk()
-
-		if err != nil {
-			return err
+	var err error
+	switch {
+	case um.mode == UploadModeStreaming && um.upload.size >= 0:
+		// the length of a deferred upload is only known once the stream ends, too late for a single PATCH, so
+		//   deferred uploads are always chunked
+		err = um.uploadStream()
+	case um.mode == UploadModeAdaptive:
+		err = um.uploadAdaptive()
+	default:
+		for !um.complete() && !um.aborted.Load() && err == nil {
+			err = um.UploadChunk()
 		}
 	}
 
+	if errors.Is(err, ErrUploadAborted) {
+		return nil
+	}
+	if err != nil || !um.complete() {
+		return err
+	}
+	um.release()
+	return um.verifyIntegrity()
+}
+
+// Terminate deletes the upload from the server, see Client.TerminateUpload, and drops the state recorded to resume it
+func (um *UploadMgr) Terminate(_ctx context.Context) error {
+	if err := um.client.TerminateUpload(_ctx, um.url); err != nil {
+		return err
+	}
+
+	if um.upload.Fingerprint != "" {
+		um.client.Config.Store.Delete(um.upload.Fingerprint)
+	}
+	um.release()
 	return nil
 }
 
+// release drops state recorded only to resume the upload, such as the nonce of an encrypted upload
+func (um *UploadMgr) release() {
+	if um.upload.release != nil {
+		um.upload.release()
+	}
+}
+
 func (um *UploadMgr) UploadChunk() error {
-	_, err := um.upload.stream.Seek(um.offset, io.SeekStart)
+	return um.uploadChunkSized(um.client.Config.ChunkSizeBytes)
+}
+
+// complete reports whether the server has every byte, never true for a deferred upload until its length is sent
+func (um *UploadMgr) complete() bool {
+	return um.upload.size >= 0 && um.offset >= um.upload.size
+}
+
+func (um *UploadMgr) uploadChunkSized(_chunkSize int64) error {
+	err := um.sendChunk(_chunkSize)
+	if errors.Is(err, ErrUnauthorized) && um.client.Config.Authenticator != nil {
+		// the body of a rejected chunk is gone, credentials were refreshed with the 401 so read it again and resend
+		slog.Warn("chunk unauthorized, resending with refreshed credentials", "offset", um.offset)
+		err = um.sendChunk(_chunkSize)
+	}
+	return err
+}
+
+func (um *UploadMgr) sendChunk(_chunkSize int64) error {
+	if um.upload.size >= 0 {
+		_chunkSize = min(_chunkSize, um.upload.size-um.offset)
+	}
+
+	body, size, err := um.chunkBody(um.offset, _chunkSize)
 	if err != nil {
 		return err
 	}
 
-	buf := make([]byte, um.client.Config.ChunkSizeBytes)
-	size, err := um.upload.stream.Read(buf)
+	// a short chunk of a deferred upload is the end of the stream, so the length is finally known
+	uploadLength := int64(-1)
+	if um.upload.size < 0 && size < _chunkSize {
+		uploadLength = um.offset + size
+	}
+
+	checksum, err := um.checksumReader(body)
+	if err == nil {
+		_, err = body.Seek(0, io.SeekStart)
+	}
 	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
 		return err
 	}
 
-	// buffer size may exceed bytes read, so cap to read size
-	checksum, err := um.Checksum(buf[:size])
-
-	body := bytes.NewBuffer(buf[:size])
+	ctx := um.ctx
+	if um.client.Config.ChunkTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, um.client.Config.ChunkTimeout)
+		defer cancel()
+	}
 
-	offset, err := um.client.uploadChunk(um.url, body, checksum, int64(size), um.offset)
+	source, confirm := um.digest.tee(body, um.offset)
+	offset, err := um.client.uploadChunk(ctx, um.url, um.throttle(ctx, source), checksum, size, um.offset, uploadLength)
 	if err != nil {
-		slog.Warn("Unexpected error while uploading chunk: %v", err)
+		confirm(um.offset)
+		if um.aborted.Load() {
+			return ErrUploadAborted
+		}
+		slog.Warn("Unexpected error while uploading chunk", "err", err)
 		return err
 	}
 
+	confirm(offset)
+	if uploadLength >= 0 {
+		um.upload.size = uploadLength
+	}
+
 	um.offset = offset
-	um.upload.setOffset(offset)
-	um.notifyChan <- true
+	um.publish(offset)
 
 	return nil
 }
 
+// chunkBody returns a reader over the chunk at _offset along with its size. Sources with random access are read
+// through an io.SectionReader without copying and without touching the shared stream, so chunks of the same upload
+// can be read in parallel. Other sources are read into a pooled buffer which is released when the body is closed.
+func (um *UploadMgr) chunkBody(_offset int64, _size int64) (io.ReadSeeker, int64, error) {
+	if um.upload.readerAt != nil && um.upload.size >= 0 {
+		return &sizedChunk{ReadSeeker: io.NewSectionReader(um.upload.readerAt, _offset, _size), offset: _offset, size: _size}, _size, nil
+	}
+
+	_, err := um.upload.stream.Seek(_offset, io.SeekStart)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// a single Read may legitimately return less than asked for, so fill the whole chunk. EOF together with the
+	//   final bytes is fine, only a stream ending before the declared upload size is an error.
+	buf := getChunkBuffer(_size)
+	size, err := io.ReadFull(um.upload.stream, *buf)
+	if um.upload.size < 0 && (errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)) {
+		// end of a deferred upload
+		*buf = (*buf)[:size]
+	} else if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		chunkBufferPool.Put(buf)
+		return nil, 0, shortStreamError(_offset+int64(size), um.upload.size)
+	} else if err != nil {
+		chunkBufferPool.Put(buf)
+		return nil, 0, err
+	}
+
+	return &pooledChunk{Reader: bytes.NewReader(*buf), buf: buf}, int64(size), nil
+}
+
+func shortStreamError(_read int64, _size int64) error {
+	return fmt.Errorf("%w: stream ended at %d bytes, upload size is %d bytes", ErrShortStream, _read, _size)
+}
+
 func (um *UploadMgr) Checksum(_bytes []byte) (string, error) {
+	return um.checksumReader(bytes.NewReader(_bytes))
+}
+
+func (um *UploadMgr) checksumReader(_reader io.Reader) (string, error) {
 	if um.client.Config.ChecksumFunc == nil || um.client.Config.ChecksumAlg == "" {
 		return "", nil
 	}
 
-	_, err := (*um.client.Config.ChecksumFunc).Write(_bytes)
-	if err != nil {
+	hasher := um.client.Config.ChecksumFunc()
+	if _, err := io.Copy(hasher, _reader); err != nil {
 		return "", err
 	}
-	checksum := (*um.client.Config.ChecksumFunc).Sum(nil)
+	checksum := hasher.Sum(nil)
 	encoded := base64.StdEncoding.EncodeToString(checksum)
 	return fmt.Sprintf("%s %s", um.client.Config.ChecksumAlg, encoded), nil
 }
 
+// Subscribe receives a copy of the upload each time progress is published, it may be called while uploading
 func (um *UploadMgr) Subscribe(upload chan Upload) {
+	um.subsMu.Lock()
+	defer um.subsMu.Unlock()
 	um.uploadSubs = append(um.uploadSubs, upload)
 }
+
+// chunkBufferPool holds chunk buffers for sources without random access, avoiding a ChunkSizeBytes allocation per chunk
+var chunkBufferPool sync.Pool
+
+func getChunkBuffer(_size int64) *[]byte {
+	if buf, ok := chunkBufferPool.Get().(*[]byte); ok && int64(cap(*buf)) >= _size {
+		*buf = (*buf)[:_size]
+		return buf
+	}
+
+	buf := make([]byte, _size)
+	return &buf
+}
+
+// sizedChunk reports a source shorter than the declared upload size rather than sending a truncated body
+type sizedChunk struct {
+	io.ReadSeeker
+	offset int64
+	size   int64
+	read   int64
+}
+
+func (c *sizedChunk) Read(p []byte) (int, error) {
+	n, err := c.ReadSeeker.Read(p)
+	c.read += int64(n)
+	if errors.Is(err, io.EOF) && c.read < c.size {
+		return n, shortStreamError(c.offset+c.read, c.offset+c.size)
+	}
+	return n, err
+}
+
+func (c *sizedChunk) Seek(offset int64, whence int) (int64, error) {
+	position, err := c.ReadSeeker.Seek(offset, whence)
+	if err == nil {
+		c.read = position
+	}
+	return position, err
+}
+
+// pooledChunk returns its buffer to chunkBufferPool once the http transport has closed the request body
+type pooledChunk struct {
+	*bytes.Reader
+	buf  *[]byte
+	once sync.Once
+}
+
+func (c *pooledChunk) Close() error {
+	c.once.Do(func() {
+		chunkBufferPool.Put(c.buf)
+	})
+	return nil
+}
@@ -0,0 +1,87 @@
+package tusc
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// trickleReader returns one byte per Read and io.EOF alongside the final byte
+type trickleReader struct {
+	*bytes.Reader
+}
+
+func (r *trickleReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n, err := r.Reader.Read(p[:1])
+	if err == nil && r.Reader.Len() == 0 {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func newTestUploadMgr(_upload *Upload, _chunkSize int64) *UploadMgr {
+	config := DefaultConfig()
+	config.ChunkSizeBytes = _chunkSize
+	return &UploadMgr{
+		client: &Client{Config: config},
+		upload: _upload,
+	}
+}
+
+func TestChunkBodyFillsShortReads(t *testing.T) {
+	fingerprint := "fingerprint-TestChunkBodyFillsShortReads"
+	upload, err := NewUpload(&trickleReader{bytes.NewReader([]byte("1234567890"))}, 10, nil, &fingerprint)
+	assert.Nil(t, err)
+
+	um := newTestUploadMgr(upload, 4)
+
+	body, size, err := um.chunkBody(0, 4)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 4, size)
+	content, _ := io.ReadAll(body)
+	assert.Equal(t, "1234", string(content))
+
+	// final chunk arrives with io.EOF
+	body, size, err = um.chunkBody(8, 2)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, size)
+	content, _ = io.ReadAll(body)
+	assert.Equal(t, "90", string(content))
+}
+
+func TestChunkBodyShortStream(t *testing.T) {
+	fingerprint := "fingerprint-TestChunkBodyShortStream"
+	upload, err := NewUpload(&readSeekerOnly{bytes.NewReader([]byte("12345"))}, 10, nil, &fingerprint)
+	assert.Nil(t, err)
+
+	_, _, err = newTestUploadMgr(upload, 10).chunkBody(0, 10)
+	assert.ErrorIs(t, err, ErrShortStream)
+}
+
+func TestChunkBodyShortReaderAt(t *testing.T) {
+	fingerprint := "fingerprint-TestChunkBodyShortReaderAt"
+	upload, err := NewUploadFromReaderAt(readerAtOnly{bytes.NewReader([]byte("12345"))}, 10, nil, &fingerprint)
+	assert.Nil(t, err)
+
+	body, _, err := newTestUploadMgr(upload, 10).chunkBody(0, 10)
+	assert.Nil(t, err)
+
+	_, err = io.ReadAll(body)
+	assert.ErrorIs(t, err, ErrShortStream)
+}
+
+func TestChecksumReaderRepeatable(t *testing.T) {
+	um := &UploadMgr{client: &Client{Config: &Config{ChecksumAlg: "sha1", ChecksumFunc: sha1.New}}}
+
+	first, err := um.checksumReader(bytes.NewReader([]byte("12345")))
+	assert.Nil(t, err)
+	second, err := um.checksumReader(bytes.NewReader([]byte("12345")))
+	assert.Nil(t, err)
+	assert.Equal(t, first, second)
+}
@@ -0,0 +1,132 @@
+package tusc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultMaxMetadataBytes largest Upload-Metadata header sent, proxies commonly reject header lines over 8KiB
+const DefaultMaxMetadataBytes = 4096
+
+// MetadataError a metadata key or header rejected by Metadata.Validate, DecodeMetadata or Config.MaxMetadataBytes.
+// Matches ErrBadMetadata, or ErrMetadataTooLarge when over the size limit.
+type MetadataError struct {
+	// Key the offending key, empty when the header as a whole is rejected
+	Key    string
+	Reason string
+	err    error
+}
+
+func (e *MetadataError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("%v: %s", e.err, e.Reason)
+	}
+	return fmt.Sprintf("%v: key %q: %s", e.err, e.Key, e.Reason)
+}
+
+func (e *MetadataError) Unwrap() error {
+	return e.err
+}
+
+func newMetadataError(_key string, _reason string) *MetadataError {
+	return &MetadataError{Key: _key, Reason: _reason, err: ErrBadMetadata}
+}
+
+// Validate checks each key is non-empty printable ASCII without spaces or commas, as the tus spec requires
+func (m Metadata) Validate() error {
+	for _, key := range m.keys() {
+		if err := validateMetadataKey(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Encode validates the metadata and returns the Upload-Metadata header. Keys are sorted so equal metadata always
+// encodes the same, a key with an empty value is sent alone.
+func (m Metadata) Encode() (string, error) {
+	if err := m.Validate(); err != nil {
+		return "", err
+	}
+	return m.encode(), nil
+}
+
+func (m Metadata) encode() string {
+	encoded := make([]string, 0, len(m))
+	for _, key := range m.keys() {
+		if m[key] == "" {
+			encoded = append(encoded, key)
+		} else {
+			encoded = append(encoded, key+" "+base64.StdEncoding.EncodeToString([]byte(m[key])))
+		}
+	}
+	return strings.Join(encoded, ",")
+}
+
+func (m Metadata) keys() []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func validateMetadataKey(_key string) error {
+	if _key == "" {
+		return newMetadataError(_key, "empty key")
+	}
+	for _, r := range _key {
+		switch {
+		case r == ' ' || r == ',':
+			return newMetadataError(_key, "contains a space or comma")
+		case r < 0x21 || r > 0x7e:
+			return newMetadataError(_key, "not printable ASCII")
+		}
+	}
+	return nil
+}
+
+// encodeMetadataLimit encodes _metadata, rejecting headers longer than _limit bytes
+func encodeMetadataLimit(_metadata Metadata, _limit int) (string, error) {
+	encoded, err := _metadata.Encode()
+	if err != nil {
+		return "", err
+	}
+	if len(encoded) > _limit {
+		return "", &MetadataError{
+			Reason: fmt.Sprintf("%d bytes encoded, limit is %d", len(encoded), _limit),
+			err:    ErrMetadataTooLarge,
+		}
+	}
+	return encoded, nil
+}
+
+// DecodeMetadata parses an Upload-Metadata header, the inverse of Metadata.Encode. Pairs are a key and a base64
+// value separated by a space, a key alone has an empty value.
+func DecodeMetadata(_header string) (Metadata, error) {
+	metadata := make(Metadata)
+	if strings.TrimSpace(_header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(_header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, newMetadataError(key, "empty key")
+		}
+		if _, ok := metadata[key]; ok {
+			return nil, newMetadataError(key, "duplicate key")
+		}
+
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, newMetadataError(key, err.Error())
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,84 @@
+package tusc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataEncode(t *testing.T) {
+	metadata := Metadata{"type": "text/plain", "filename": "a.txt", "empty": "", "b": "2"}
+
+	encoded, err := metadata.Encode()
+	assert.Nil(t, err)
+	assert.Equal(t, "b Mg==,empty,filename YS50eHQ=,type dGV4dC9wbGFpbg==", encoded)
+
+	// map iteration order never leaks into the header
+	for i := 0; i < 10; i++ {
+		again, err := metadata.Encode()
+		assert.Nil(t, err)
+		assert.Equal(t, encoded, again)
+	}
+
+	encoded, err = Metadata{}.Encode()
+	assert.Nil(t, err)
+	assert.Equal(t, "", encoded)
+}
+
+func TestMetadataValidate(t *testing.T) {
+	assert.Nil(t, Metadata{"filename": "a b,c", "x-Custom_key.1": ""}.Validate())
+	assert.Nil(t, Metadata(nil).Validate())
+
+	for _, key := range []string{"", "file name", "a,b", "tab\t", "naïve", "\x7f"} {
+		err := Metadata{key: "value"}.Validate()
+		assert.ErrorIs(t, err, ErrBadMetadata, key)
+
+		var metadataErr *MetadataError
+		if assert.True(t, errors.As(err, &metadataErr), key) {
+			assert.Equal(t, key, metadataErr.Key)
+		}
+
+		_, err = Metadata{key: "value"}.Encode()
+		assert.ErrorIs(t, err, ErrBadMetadata, key)
+	}
+}
+
+func TestEncodeMetadataLimit(t *testing.T) {
+	metadata := Metadata{"filename": strings.Repeat("a", 30)}
+
+	encoded, err := encodeMetadataLimit(metadata, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, metadata.encode(), encoded)
+
+	_, err = encodeMetadataLimit(metadata, 10)
+	assert.ErrorIs(t, err, ErrMetadataTooLarge)
+	assert.NotErrorIs(t, err, ErrBadMetadata)
+
+	_, err = encodeMetadataLimit(Metadata{"a b": ""}, 100)
+	assert.ErrorIs(t, err, ErrBadMetadata)
+}
+
+func TestDecodeMetadata(t *testing.T) {
+	upload, err := NewUploadFromBytes([]byte("x"), nil)
+	assert.Nil(t, err)
+	upload.Metadata = Metadata{"filename": "a b,c.txt", "type": "text/plain", "empty": ""}
+
+	metadata, err := DecodeMetadata(upload.EncodedMetadata())
+	assert.Nil(t, err)
+	assert.Equal(t, upload.Metadata, metadata)
+
+	metadata, err = DecodeMetadata("flag, name Zm9v")
+	assert.Nil(t, err)
+	assert.Equal(t, Metadata{"flag": "", "name": "foo"}, metadata)
+
+	metadata, err = DecodeMetadata("")
+	assert.Nil(t, err)
+	assert.Empty(t, metadata)
+
+	for _, header := range []string{"name Zm9v,name YmFy", "name Zm9v,,", "name !!!"} {
+		_, err = DecodeMetadata(header)
+		assert.ErrorIs(t, err, ErrBadMetadata, header)
+	}
+}
@@ -0,0 +1,28 @@
+package tusc
+
+import (
+	"net/http"
+)
+
+// Middleware wraps the http.RoundTripper every request of a Client goes through, see Config.Middleware. Requests
+// reach middleware with Config.Header, Tus-Resumable and method overrides applied. As with any http.RoundTripper,
+// clone a request before changing it.
+type Middleware func(_next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc allows an ordinary function to be used as an http.RoundTripper.
+type RoundTripperFunc func(_req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(_req *http.Request) (*http.Response, error) {
+	return f(_req)
+}
+
+// ChainMiddleware composes _middleware into one, the first is the outermost: it sees requests first and responses
+// last.
+func ChainMiddleware(_middleware ...Middleware) Middleware {
+	return func(_next http.RoundTripper) http.RoundTripper {
+		for i := len(_middleware) - 1; i >= 0; i-- {
+			_next = _middleware[i](_next)
+		}
+		return _next
+	}
+}
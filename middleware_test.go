@@ -0,0 +1,47 @@
+package tusc
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainMiddleware(t *testing.T) {
+	var calls []string
+	trace := func(_name string) Middleware {
+		return func(_next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(_req *http.Request) (*http.Response, error) {
+				calls = append(calls, _name+" request")
+				res, err := _next.RoundTrip(_req)
+				calls = append(calls, _name+" response")
+				return res, err
+			})
+		}
+	}
+
+	final := RoundTripperFunc(func(_req *http.Request) (*http.Response, error) {
+		calls = append(calls, "send")
+		return &http.Response{StatusCode: http.StatusNoContent}, nil
+	})
+
+	req, err := http.NewRequest(http.MethodHead, "http://example.com", nil)
+	assert.Nil(t, err)
+
+	res, err := ChainMiddleware(trace("a"), trace("b"))(final).RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Equal(t, "a request,b request,send,b response,a response", strings.Join(calls, ","))
+
+	calls = nil
+	_, err = ChainMiddleware()(final).RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"send"}, calls)
+}
+
+func TestConfigNilMiddleware(t *testing.T) {
+	c := DefaultConfig()
+	c.Middleware = []Middleware{nil}
+	assert.ErrorIs(t, c.ValidateAndSetDefaults(), ErrNilMiddleware)
+}
@@ -0,0 +1,264 @@
+package tusc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProbeInterval = 30 * time.Second
+
+	// queueEntryKeyPrefix keeps entries apart from the upload URLs Config.Store maps fingerprints to
+	queueEntryKeyPrefix = "queue:"
+)
+
+// QueueEntry a pending upload persisted by an OfflineQueue
+type QueueEntry struct {
+	Path        string    `json:"path"`
+	Fingerprint string    `json:"fingerprint"`
+	Metadata    Metadata  `json:"metadata,omitempty"`
+	Priority    int       `json:"priority,omitempty"`
+	Added       time.Time `json:"added"`
+}
+
+// OfflineQueue is a durable queue of file uploads which drains whenever the server is reachable. Entries are
+// persisted in a ListableStore (e.g. a FileStore, optionally wrapped in an EncryptedStore) keyed "queue:" +
+// fingerprint, and only removed once uploaded, so pending work survives restarts. Config.Store should also be
+// persistent so partially uploaded files resume rather than restart, and may be used for the entries as well.
+type OfflineQueue struct {
+	// ProbeInterval how often reachability is checked while offline or idle
+	ProbeInterval time.Duration
+
+	baseUrl string
+	config  *Config
+	entries ListableStore
+	workers int
+
+	mu     sync.Mutex
+	client *Client
+	wake   chan struct{}
+}
+
+func NewOfflineQueue(_baseUrl string, _config *Config, _entries ListableStore, _workers int) (*OfflineQueue, error) {
+	if _baseUrl == "" {
+		return nil, errors.New("BaseUrl cannot be empty")
+	}
+	if _entries == nil {
+		return nil, ErrNilStore
+	}
+	if _workers < 1 {
+		return nil, ErrBadWorkers
+	}
+	if _config == nil {
+		_config = DefaultConfig()
+	} else if err := _config.ValidateAndSetDefaults(); err != nil {
+		return nil, err
+	}
+
+	return &OfflineQueue{
+		ProbeInterval: defaultProbeInterval,
+		baseUrl:       _baseUrl,
+		config:        _config,
+		entries:       _entries,
+		workers:       _workers,
+		wake:          make(chan struct{}, 1),
+	}, nil
+}
+
+// Enqueue persists a file for upload. If _fingerprint is nil one is generated with Config.Fingerprinter, or
+// FileInfoFingerprinter if unset. Enqueueing a fingerprint that is already pending replaces the entry.
+func (q *OfflineQueue) Enqueue(_path string, _metadata Metadata, _fingerprint *string, _priority int) (*QueueEntry, error) {
+	// rejected now rather than on every drain
+	if err := _metadata.Validate(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(_path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	upload, err := NewUploadFromFile(file, _fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	if upload.Fingerprint == "" {
+		fingerprinter := q.config.Fingerprinter
+		if fingerprinter == nil {
+			fingerprinter = &FileInfoFingerprinter{}
+		}
+		if err = upload.GenerateFingerprint(fingerprinter); err != nil {
+			return nil, err
+		}
+	}
+
+	entry := &QueueEntry{
+		Path:        _path,
+		Fingerprint: upload.Fingerprint,
+		Metadata:    _metadata,
+		Priority:    _priority,
+		Added:       time.Now().UTC(),
+	}
+
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	q.entries.Set(queueEntryKey(entry.Fingerprint), string(value))
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return entry, nil
+}
+
+// Pending returns the entries not yet uploaded
+func (q *OfflineQueue) Pending() []QueueEntry {
+	var pending []QueueEntry
+	for _, key := range q.entries.Keys() {
+		if !strings.HasPrefix(key, queueEntryKeyPrefix) {
+			continue
+		}
+		if entry, ok := q.entry(key); ok {
+			pending = append(pending, entry)
+		}
+	}
+	return pending
+}
+
+// Remove drops a pending entry without uploading it
+func (q *OfflineQueue) Remove(_fingerprint string) {
+	q.entries.Delete(queueEntryKey(_fingerprint))
+}
+
+// Run drains the queue whenever the server is reachable until _ctx is cancelled. Reachability is probed with the
+// OPTIONS request used by NewClient.
+func (q *OfflineQueue) Run(_ctx context.Context) error {
+	for {
+		if len(q.Pending()) != 0 {
+			if _, err := q.Drain(_ctx); err != nil {
+				slog.Info("upload server unreachable, waiting", "err", err)
+			}
+		}
+
+		timer := time.NewTimer(q.ProbeInterval)
+		select {
+		case <-_ctx.Done():
+			timer.Stop()
+			return _ctx.Err()
+		case <-q.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Drain uploads every pending entry once. An error is returned only when the server is unreachable, individual
+// upload failures are left queued for the next attempt and reported in the summary.
+func (q *OfflineQueue) Drain(_ctx context.Context) (QueueSummary, error) {
+	client, err := q.probe()
+	if err != nil {
+		return QueueSummary{}, err
+	}
+
+	queue, err := NewUploadQueue(client, q.workers)
+	if err != nil {
+		return QueueSummary{}, err
+	}
+
+	// items failing to open never learn their fingerprint
+	fingerprints := make(map[*QueueItem]string)
+	for _, entry := range q.Pending() {
+		item, err := queue.AddFunc(func() (*Upload, error) {
+			return openQueueEntry(entry)
+		}, entry.Priority)
+		if err != nil {
+			return QueueSummary{}, err
+		}
+		fingerprints[item] = entry.Fingerprint
+	}
+
+	stop := context.AfterFunc(_ctx, queue.CancelAll)
+	defer stop()
+
+	summary := queue.Wait()
+
+	for _, item := range summary.Succeeded {
+		q.Remove(fingerprints[item])
+	}
+	for _, item := range summary.Failed {
+		if errors.Is(item.Err(), os.ErrNotExist) {
+			// the file is gone, nothing will ever succeed
+			slog.Warn("dropping queued upload, file no longer exists", "fingerprint", fingerprints[item], "err", item.Err())
+			q.Remove(fingerprints[item])
+		}
+	}
+
+	return summary, nil
+}
+
+// probe checks the server is reachable, creating the client on first contact
+func (q *OfflineQueue) probe() (*Client, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.client == nil {
+		client, err := NewClient(q.baseUrl, q.config)
+		if err != nil {
+			return nil, err
+		}
+		q.client = client
+		return client, nil
+	}
+
+	return q.client, q.client.Probe()
+}
+
+func (q *OfflineQueue) entry(_key string) (QueueEntry, bool) {
+	var entry QueueEntry
+
+	value, ok := q.entries.Get(_key)
+	if !ok {
+		return entry, false
+	}
+
+	if err := json.Unmarshal([]byte(value), &entry); err != nil {
+		slog.Warn("ignoring unreadable queue entry", "key", _key, "err", err)
+		return entry, false
+	}
+
+	return entry, true
+}
+
+func queueEntryKey(_fingerprint string) string {
+	return queueEntryKeyPrefix + _fingerprint
+}
+
+func openQueueEntry(_entry QueueEntry) (*Upload, error) {
+	file, err := os.Open(_entry.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	upload, err := NewUploadFromFile(file, &_entry.Fingerprint)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	for k, v := range _entry.Metadata {
+		upload.Metadata[k] = v
+	}
+
+	return upload, nil
+}
@@ -0,0 +1,238 @@
+package tusc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tus/tusd/pkg/filestore"
+	tusd "github.com/tus/tusd/pkg/handler"
+)
+
+// switchableServer is a tus server which drops every connection while offline
+type switchableServer struct {
+	url    string
+	dir    string
+	online atomic.Bool
+}
+
+func newSwitchableServer(t *testing.T) *switchableServer {
+	s := &switchableServer{dir: t.TempDir()}
+
+	composer := tusd.NewStoreComposer()
+	filestore.New(s.dir).UseIn(composer)
+
+	handler, err := tusd.NewHandler(tusd.Config{
+		BasePath:      "/uploads/",
+		StoreComposer: composer,
+	})
+	assert.Nil(t, err)
+	tus := http.StripPrefix("/uploads/", handler)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.online.Load() {
+			if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+				conn.Close()
+			}
+			return
+		}
+		tus.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	s.url = ts.URL + "/uploads/"
+	return s
+}
+
+func (s *switchableServer) content(t *testing.T, _url string) []byte {
+	content, err := os.ReadFile(filepath.Join(s.dir, filepath.Base(_url)))
+	assert.Nil(t, err)
+	return content
+}
+
+func writeQueueTestFile(t *testing.T, _content string) string {
+	path := filepath.Join(t.TempDir(), "file")
+	assert.Nil(t, os.WriteFile(path, []byte(_content), 0o600))
+	return path
+}
+
+func TestOfflineQueueEnqueue(t *testing.T) {
+	path := writeQueueTestFile(t, "1234567890")
+	queue, err := NewOfflineQueue("https://tus.example.org/files/", nil, NewMemoryStore(), 1)
+	assert.Nil(t, err)
+
+	_, err = queue.Enqueue(path, Metadata{"bad key": "value"}, nil, 0)
+	assert.ErrorIs(t, err, ErrBadMetadata)
+
+	_, err = queue.Enqueue(filepath.Join(t.TempDir(), "missing"), nil, nil, 0)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	fingerprint := "fingerprint-TestOfflineQueueEnqueue"
+	_, err = queue.Enqueue(path, nil, &fingerprint, 1)
+	assert.Nil(t, err)
+	entry, err := queue.Enqueue(path, Metadata{"filename": "file"}, &fingerprint, 2)
+	assert.Nil(t, err)
+
+	pending := queue.Pending()
+	assert.Len(t, pending, 1)
+	assert.Equal(t, entry.Fingerprint, pending[0].Fingerprint)
+	assert.Equal(t, 2, pending[0].Priority)
+	assert.Equal(t, Metadata{"filename": "file"}, pending[0].Metadata)
+
+	queue.Remove(fingerprint)
+	assert.Empty(t, queue.Pending())
+}
+
+func TestOfflineQueueReplaysAfterRestart(t *testing.T) {
+	server := newSwitchableServer(t)
+	server.online.Store(true)
+	path := writeQueueTestFile(t, "1234567890")
+	entriesPath := filepath.Join(t.TempDir(), "queue.json")
+
+	entries, err := NewFileStore(entriesPath)
+	assert.Nil(t, err)
+	queue, err := NewOfflineQueue(server.url, nil, entries, 1)
+	assert.Nil(t, err)
+	_, err = queue.Enqueue(path, nil, nil, 0)
+	assert.Nil(t, err)
+	entries.Close()
+
+	entries, err = NewFileStore(entriesPath)
+	assert.Nil(t, err)
+	queue, err = NewOfflineQueue(server.url, nil, entries, 1)
+	assert.Nil(t, err)
+	assert.Len(t, queue.Pending(), 1)
+
+	summary, err := queue.Drain(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, summary.Succeeded, 1)
+	assert.Empty(t, queue.Pending())
+	assert.Equal(t, []byte("1234567890"), server.content(t, summary.Succeeded[0].URL()))
+}
+
+func TestOfflineQueueSharesConfigStore(t *testing.T) {
+	server := newSwitchableServer(t)
+	server.online.Store(true)
+	path := writeQueueTestFile(t, "1234567890")
+
+	store := NewMemoryStore()
+	config := DefaultConfig()
+	config.Store = store
+	queue, err := NewOfflineQueue(server.url, config, store, 1)
+	assert.Nil(t, err)
+	entry, err := queue.Enqueue(path, nil, nil, 0)
+	assert.Nil(t, err)
+
+	// the upload URL recorded under the bare fingerprint is never mistaken for an entry
+	store.Set(entry.Fingerprint, server.url+"unknown")
+	assert.Len(t, queue.Pending(), 1)
+
+	summary, err := queue.Drain(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, summary.Succeeded, 1)
+	assert.Empty(t, queue.Pending())
+
+	url, ok := store.Get(entry.Fingerprint)
+	assert.True(t, ok)
+	assert.Equal(t, summary.Succeeded[0].URL(), url)
+}
+
+func TestOfflineQueueConnectivity(t *testing.T) {
+	server := newSwitchableServer(t)
+	path := writeQueueTestFile(t, "1234567890")
+
+	queue, err := NewOfflineQueue(server.url, nil, NewMemoryStore(), 1)
+	assert.Nil(t, err)
+	_, err = queue.Enqueue(path, nil, nil, 0)
+	assert.Nil(t, err)
+
+	// offline before the client was ever created
+	_, err = queue.Drain(context.Background())
+	assert.NotNil(t, err)
+	assert.Len(t, queue.Pending(), 1)
+
+	server.online.Store(true)
+	summary, err := queue.Drain(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, summary.Succeeded, 1)
+	assert.Empty(t, queue.Pending())
+
+	// offline again once the client exists
+	server.online.Store(false)
+	_, err = queue.Enqueue(path, nil, nil, 0)
+	assert.Nil(t, err)
+	_, err = queue.Drain(context.Background())
+	assert.NotNil(t, err)
+	assert.Len(t, queue.Pending(), 1)
+}
+
+func TestOfflineQueueRun(t *testing.T) {
+	server := newSwitchableServer(t)
+	path := writeQueueTestFile(t, "1234567890")
+
+	queue, err := NewOfflineQueue(server.url, nil, NewMemoryStore(), 1)
+	assert.Nil(t, err)
+	queue.ProbeInterval = 20 * time.Millisecond
+	_, err = queue.Enqueue(path, nil, nil, 0)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- queue.Run(ctx)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, queue.Pending(), 1)
+
+	// drains once the server comes back
+	server.online.Store(true)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(queue.Pending()) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Empty(t, queue.Pending())
+
+	cancel()
+	assert.ErrorIs(t, <-stopped, context.Canceled)
+}
+
+func TestOfflineQueueDropsMissingFile(t *testing.T) {
+	server := newSwitchableServer(t)
+	server.online.Store(true)
+	path := writeQueueTestFile(t, "1234567890")
+
+	queue, err := NewOfflineQueue(server.url, nil, NewMemoryStore(), 1)
+	assert.Nil(t, err)
+	_, err = queue.Enqueue(path, nil, nil, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, os.Remove(path))
+
+	summary, err := queue.Drain(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, summary.Failed, 1)
+	assert.Empty(t, queue.Pending())
+}
+
+func TestProbeLeavesOption(t *testing.T) {
+	server := newSwitchableServer(t)
+	server.online.Store(true)
+
+	client, err := NewClient(server.url, nil)
+	assert.Nil(t, err)
+	option := client.Option
+	assert.NotNil(t, option)
+
+	assert.Nil(t, client.Probe())
+	assert.Same(t, option, client.Option)
+
+	server.online.Store(false)
+	assert.NotNil(t, client.Probe())
+	assert.Same(t, option, client.Option)
+}
@@ -0,0 +1,444 @@
+package tusc
+
+import (
+	"container/heap"
+	"io"
+	"sync"
+	"time"
+)
+
+type QueueItemState int
+
+const (
+	QueueItemPending QueueItemState = iota
+	QueueItemRunning
+	QueueItemSucceeded
+	QueueItemFailed
+	QueueItemCancelled
+)
+
+func (s QueueItemState) String() string {
+	switch s {
+	case QueueItemPending:
+		return "pending"
+	case QueueItemRunning:
+		return "running"
+	case QueueItemSucceeded:
+		return "succeeded"
+	case QueueItemFailed:
+		return "failed"
+	case QueueItemCancelled:
+		return "cancelled"
+	default:
+		return "unknown"
+	}
+}
+
+// UploadOpener lazily creates an upload when a worker picks up the item, so sources aren't held open while queued.
+// If the upload source is an io.Closer it is closed once the item finishes.
+type UploadOpener func() (*Upload, error)
+
+// QueueItem is a single upload in an UploadQueue
+type QueueItem struct {
+	priority int
+	open     UploadOpener
+	owned    bool
+	seq      uint64
+	index    int
+
+	mu          sync.Mutex
+	state       QueueItemState
+	uploadMgr   *UploadMgr
+	cancelled   bool
+	fingerprint string
+	url         string
+	size        int64
+	offset      int64
+	err         error
+	started     time.Time
+	finished    time.Time
+	queue       *UploadQueue
+}
+
+// Priority higher values are uploaded first, equal priorities in the order added
+func (i *QueueItem) Priority() int {
+	return i.priority
+}
+
+// Cancel removes a pending item from the queue or aborts it if running
+func (i *QueueItem) Cancel() {
+	i.queue.cancel(i)
+}
+
+func (i *QueueItem) State() QueueItemState {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.state
+}
+
+// Err returns the error the item failed with, if any
+func (i *QueueItem) Err() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.err
+}
+
+func (i *QueueItem) Fingerprint() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.fingerprint
+}
+
+// URL of the upload on the server, empty until created or resumed
+func (i *QueueItem) URL() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.url
+}
+
+// Size of the upload, 0 until opened
+func (i *QueueItem) Size() int64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.size
+}
+
+// Offset bytes confirmed by the server
+func (i *QueueItem) Offset() int64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.offset
+}
+
+// Duration time spent uploading, up to now if still running
+func (i *QueueItem) Duration() time.Duration {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.started.IsZero() {
+		return 0
+	} else if i.finished.IsZero() {
+		return time.Since(i.started)
+	}
+	return i.finished.Sub(i.started)
+}
+
+// QueueProgress aggregated progress of all items in an UploadQueue
+type QueueProgress struct {
+	Pending       int
+	Running       int
+	Succeeded     int
+	Failed        int
+	Cancelled     int
+	BytesUploaded int64
+	// BytesTotal only includes items that have been opened
+	BytesTotal int64
+}
+
+// QueueSummary final outcome of an UploadQueue
+type QueueSummary struct {
+	Succeeded []*QueueItem
+	Failed    []*QueueItem
+	Cancelled []*QueueItem
+	// BytesUploaded across all items, including partially uploaded ones
+	BytesUploaded int64
+	Duration      time.Duration
+}
+
+// UploadQueue uploads items with a bounded number of workers sharing a Client, highest priority first.
+type UploadQueue struct {
+	client  *Client
+	started time.Time
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending queueHeap
+	items   []*QueueItem
+	seq     uint64
+	closed  bool
+	workers sync.WaitGroup
+}
+
+func NewUploadQueue(_client *Client, _workers int) (*UploadQueue, error) {
+	if _client == nil {
+		return nil, ErrNilClient
+	}
+	if _workers < 1 {
+		return nil, ErrBadWorkers
+	}
+
+	q := &UploadQueue{
+		client:  _client,
+		started: time.Now(),
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	q.workers.Add(_workers)
+	for i := 0; i < _workers; i++ {
+		go q.work()
+	}
+
+	return q, nil
+}
+
+// Add queues an upload. The caller remains responsible for closing the upload source.
+func (q *UploadQueue) Add(_upload *Upload, _priority int) (*QueueItem, error) {
+	if _upload == nil {
+		return nil, ErrNilUpload
+	}
+
+	return q.add(func() (*Upload, error) {
+		return _upload, nil
+	}, false, _priority)
+}
+
+// AddFunc queues an upload created by _open once a worker is available, see UploadOpener.
+func (q *UploadQueue) AddFunc(_open UploadOpener, _priority int) (*QueueItem, error) {
+	if _open == nil {
+		return nil, ErrNilUpload
+	}
+
+	return q.add(_open, true, _priority)
+}
+
+func (q *UploadQueue) add(_open UploadOpener, _owned bool, _priority int) (*QueueItem, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil, ErrQueueClosed
+	}
+
+	item := &QueueItem{
+		priority: _priority,
+		open:     _open,
+		owned:    _owned,
+		seq:      q.seq,
+		queue:    q,
+	}
+	q.seq++
+
+	q.items = append(q.items, item)
+	heap.Push(&q.pending, item)
+	q.cond.Signal()
+
+	return item, nil
+}
+
+// CancelAll cancels every pending and running item
+func (q *UploadQueue) CancelAll() {
+	q.mu.Lock()
+	items := append([]*QueueItem(nil), q.items...)
+	q.mu.Unlock()
+
+	for _, item := range items {
+		item.Cancel()
+	}
+}
+
+func (q *UploadQueue) Progress() QueueProgress {
+	q.mu.Lock()
+	items := append([]*QueueItem(nil), q.items...)
+	q.mu.Unlock()
+
+	var progress QueueProgress
+	for _, item := range items {
+		item.mu.Lock()
+		switch item.state {
+		case QueueItemPending:
+			progress.Pending++
+		case QueueItemRunning:
+			progress.Running++
+		case QueueItemSucceeded:
+			progress.Succeeded++
+		case QueueItemFailed:
+			progress.Failed++
+		case QueueItemCancelled:
+			progress.Cancelled++
+		}
+		progress.BytesUploaded += item.offset
+		progress.BytesTotal += item.size
+		item.mu.Unlock()
+	}
+
+	return progress
+}
+
+// Wait closes the queue to new items, waits for every item to finish and returns the summary
+func (q *UploadQueue) Wait() QueueSummary {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	q.workers.Wait()
+
+	summary := QueueSummary{
+		Duration: time.Since(q.started),
+	}
+	for _, item := range q.items {
+		switch item.State() {
+		case QueueItemSucceeded:
+			summary.Succeeded = append(summary.Succeeded, item)
+		case QueueItemFailed:
+			summary.Failed = append(summary.Failed, item)
+		case QueueItemCancelled:
+			summary.Cancelled = append(summary.Cancelled, item)
+		}
+		summary.BytesUploaded += item.Offset()
+	}
+
+	return summary
+}
+
+func (q *UploadQueue) work() {
+	defer q.workers.Done()
+
+	for {
+		q.mu.Lock()
+		for q.pending.Len() == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.pending.Len() == 0 {
+			q.mu.Unlock()
+			return
+		}
+		item := heap.Pop(&q.pending).(*QueueItem)
+		item.mu.Lock()
+		item.state = QueueItemRunning
+		item.started = time.Now()
+		item.mu.Unlock()
+		q.mu.Unlock()
+
+		q.run(item)
+	}
+}
+
+func (q *UploadQueue) run(_item *QueueItem) {
+	upload, err := _item.open()
+	if err != nil {
+		q.finish(_item, err)
+		return
+	}
+	if _item.owned {
+		if closer, ok := upload.stream.(io.Closer); ok {
+			defer closer.Close()
+		}
+	}
+
+	uploadMgr, err := q.client.CreateOrResumeUpload(upload)
+
+	_item.mu.Lock()
+	_item.fingerprint = upload.Fingerprint
+	_item.size = upload.Size()
+	_item.mu.Unlock()
+
+	if err != nil {
+		q.finish(_item, err)
+		return
+	}
+
+	_item.mu.Lock()
+	_item.uploadMgr = uploadMgr
+	_item.url = uploadMgr.URL()
+	_item.offset = uploadMgr.offset
+	cancelled := _item.cancelled
+	_item.mu.Unlock()
+
+	if cancelled {
+		q.finish(_item, nil)
+		return
+	}
+
+	// progress is only published through subscriptions, and never after Upload returns
+	progress := make(chan Upload)
+	drained := make(chan struct{})
+	uploadMgr.Subscribe(progress)
+	go func() {
+		defer close(drained)
+		for u := range progress {
+			_item.mu.Lock()
+			_item.offset = u.Offset()
+			_item.mu.Unlock()
+		}
+	}()
+
+	err = uploadMgr.Upload()
+	close(progress)
+	<-drained
+
+	_item.mu.Lock()
+	_item.offset = uploadMgr.offset
+	_item.mu.Unlock()
+
+	q.finish(_item, err)
+}
+
+func (q *UploadQueue) finish(_item *QueueItem, _err error) {
+	_item.mu.Lock()
+	defer _item.mu.Unlock()
+
+	_item.finished = time.Now()
+	_item.err = _err
+	switch {
+	case _err != nil:
+		_item.state = QueueItemFailed
+	case _item.cancelled && (_item.uploadMgr == nil || _item.offset < _item.size):
+		_item.state = QueueItemCancelled
+	default:
+		_item.state = QueueItemSucceeded
+	}
+}
+
+func (q *UploadQueue) cancel(_item *QueueItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_item.mu.Lock()
+	defer _item.mu.Unlock()
+
+	switch _item.state {
+	case QueueItemPending:
+		heap.Remove(&q.pending, _item.index)
+		_item.cancelled = true
+		_item.state = QueueItemCancelled
+	case QueueItemRunning:
+		_item.cancelled = true
+		if _item.uploadMgr != nil {
+			_item.uploadMgr.Abort()
+		}
+	}
+}
+
+// queueHeap orders items by descending priority then insertion order
+type queueHeap []*QueueItem
+
+func (h queueHeap) Len() int {
+	return len(h)
+}
+
+func (h queueHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h queueHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *queueHeap) Push(x any) {
+	item := x.(*QueueItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *queueHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	item.index = -1
+	return item
+}
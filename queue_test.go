@@ -0,0 +1,27 @@
+package tusc
+
+import (
+	"container/heap"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueHeapOrder(t *testing.T) {
+	var h queueHeap
+	for seq, priority := range []int{0, 5, 0, 10, 5} {
+		heap.Push(&h, &QueueItem{priority: priority, seq: uint64(seq)})
+	}
+
+	var order []uint64
+	for h.Len() > 0 {
+		order = append(order, heap.Pop(&h).(*QueueItem).seq)
+	}
+
+	assert.Equal(t, []uint64{3, 1, 4, 0, 2}, order)
+}
+
+func TestQueueItemStateString(t *testing.T) {
+	assert.Equal(t, "cancelled", QueueItemCancelled.String())
+	assert.Equal(t, "unknown", QueueItemState(-1).String())
+}
@@ -0,0 +1,137 @@
+package tusc
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiterMaxSleep bounds each wait so a rate changed with SetRate applies promptly to blocked readers
+const rateLimiterMaxSleep = 50 * time.Millisecond
+
+// RateLimiter is a token bucket limiting bytes per second. It is safe for concurrent use, so a single RateLimiter
+// shared between uploads caps their combined bandwidth. The rate may be changed at any time with SetRate.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter allowing _bytesPerSecond, a rate <= 0 is unlimited.
+func NewRateLimiter(_bytesPerSecond int64) *RateLimiter {
+	return &RateLimiter{
+		rate:   _bytesPerSecond,
+		tokens: float64(max(_bytesPerSecond, 0)),
+		last:   time.Now(),
+	}
+}
+
+// SetRate changes the limit, taking effect for reads already in progress. A rate <= 0 is unlimited.
+func (r *RateLimiter) SetRate(_bytesPerSecond int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refill()
+	r.rate = _bytesPerSecond
+	r.tokens = min(r.tokens, float64(r.burst()))
+}
+
+// Rate returns the current limit in bytes per second, <= 0 is unlimited.
+func (r *RateLimiter) Rate() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rate
+}
+
+// WaitN blocks until _n bytes may be sent. _n larger than the burst (one second at the current rate) is waited for
+// in burst sized steps.
+func (r *RateLimiter) WaitN(_ctx context.Context, _n int) error {
+	remaining := int64(_n)
+	for remaining > 0 {
+		r.mu.Lock()
+		r.refill()
+		if r.rate <= 0 {
+			r.mu.Unlock()
+			return nil
+		}
+
+		step := min(remaining, r.burst())
+		if r.tokens >= float64(step) {
+			r.tokens -= float64(step)
+			remaining -= step
+			r.mu.Unlock()
+			continue
+		}
+
+		wait := time.Duration((float64(step) - r.tokens) / float64(r.rate) * float64(time.Second))
+		r.mu.Unlock()
+
+		timer := time.NewTimer(min(wait, rateLimiterMaxSleep))
+		select {
+		case <-_ctx.Done():
+			timer.Stop()
+			return _ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return nil
+}
+
+// burst is one second's worth of tokens, callers must hold mu
+func (r *RateLimiter) burst() int64 {
+	return max(r.rate, 1)
+}
+
+// refill adds tokens for the time elapsed since the last refill, callers must hold mu
+func (r *RateLimiter) refill() {
+	now := time.Now()
+	if r.rate > 0 {
+		r.tokens = min(r.tokens+now.Sub(r.last).Seconds()*float64(r.rate), float64(r.burst()))
+	}
+	r.last = now
+}
+
+// throttledReader limits reads from reader with limiter, passing Close through so pooled chunks are still released
+type throttledReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *RateLimiter
+}
+
+func newThrottledReader(_ctx context.Context, _reader io.Reader, _limiter *RateLimiter) io.Reader {
+	if _limiter == nil {
+		return _reader
+	}
+
+	return &throttledReader{
+		ctx:     _ctx,
+		reader:  _reader,
+		limiter: _limiter,
+	}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if rate := t.limiter.Rate(); rate > 0 && int64(len(p)) > rate {
+		p = p[:rate]
+	}
+
+	n, err := t.reader.Read(p)
+	if n > 0 {
+		if waitErr := t.limiter.WaitN(t.ctx, n); waitErr != nil {
+			// the cause tells an aborted upload apart from a timeout
+			return n, context.Cause(t.ctx)
+		}
+	}
+
+	return n, err
+}
+
+func (t *throttledReader) Close() error {
+	if closer, ok := t.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package tusc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterLimits(t *testing.T) {
+	const rate = 512 * 1024
+	limiter := NewRateLimiter(rate)
+
+	started := time.Now()
+	n, err := io.Copy(io.Discard, newThrottledReader(context.Background(), bytes.NewReader(make([]byte, 2*rate)), limiter))
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2*rate, n)
+
+	// the first second is available as burst
+	assert.GreaterOrEqual(t, time.Since(started), 900*time.Millisecond)
+}
+
+func TestRateLimiterSetRate(t *testing.T) {
+	limiter := NewRateLimiter(1024)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		limiter.SetRate(0)
+	}()
+
+	started := time.Now()
+	_, err := io.Copy(io.Discard, newThrottledReader(context.Background(), bytes.NewReader(make([]byte, 1024*1024)), limiter))
+	assert.Nil(t, err)
+	assert.Less(t, time.Since(started), 2*time.Second)
+}
+
+func TestRateLimiterContext(t *testing.T) {
+	limiter := NewRateLimiter(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := limiter.WaitN(ctx, 1024)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestThrottleAppliesClientAndUploadLimits(t *testing.T) {
+	const rate = 512 * 1024
+	config := DefaultConfig()
+	config.RateLimiter = NewRateLimiter(rate)
+	um, err := NewUploadMgr(&Client{Config: config}, "", nil, 0)
+	assert.Nil(t, err)
+
+	// a faster per upload limit must not lift the client wide cap
+	um.SetRateLimiter(NewRateLimiter(100 * rate))
+
+	started := time.Now()
+	n, err := io.Copy(io.Discard, um.throttle(context.Background(), bytes.NewReader(make([]byte, 2*rate))))
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2*rate, n)
+	assert.GreaterOrEqual(t, time.Since(started), 900*time.Millisecond)
+}
+
+func TestAbortInterruptsThrottle(t *testing.T) {
+	config := DefaultConfig()
+	um, err := NewUploadMgr(&Client{Config: config}, "", nil, 0)
+	assert.Nil(t, err)
+	um.SetRateLimiter(NewRateLimiter(1))
+	um.begin()
+	defer um.end()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		um.Abort()
+	}()
+
+	started := time.Now()
+	_, err = io.Copy(io.Discard, um.throttle(um.ctx, bytes.NewReader(make([]byte, 1024))))
+	assert.ErrorIs(t, err, ErrUploadAborted)
+	assert.Less(t, time.Since(started), 2*time.Second)
+}
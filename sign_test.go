@@ -0,0 +1,94 @@
+package tusc
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalRequest(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPatch, "http://example.com/files/a%20b?x=1", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Upload-Offset", "1024")
+	req.Header.Add("X-Multi", "a")
+	req.Header.Add("X-Multi", "b")
+
+	assert.Equal(t, "PATCH\n/files/a%20b\n1718035200\nupload-offset:1024\nupload-length:\nx-multi:a,b",
+		CanonicalRequest(req, "1718035200", []string{"Upload-Offset", "Upload-Length", "X-Multi"}))
+}
+
+func TestHMACSigner(t *testing.T) {
+	now := time.Unix(1718035200, 0)
+	signer := &HMACSigner{Key: []byte("secret"), KeyID: "k1", Now: func() time.Time { return now }}
+
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(http.MethodPatch, "http://example.com/files/a", nil)
+		assert.Nil(t, err)
+		req.Header.Set("Upload-Offset", "1024")
+		assert.Nil(t, signer.Sign(req))
+		return req
+	}
+
+	req := newRequest()
+	assert.Equal(t, "1718035200", req.Header.Get(DefaultTimestampHeader))
+	assert.Equal(t, "k1", req.Header.Get(DefaultKeyIDHeader))
+	assert.NotEmpty(t, req.Header.Get(DefaultSignatureHeader))
+	assert.Nil(t, signer.Verify(req, time.Minute))
+
+	// anything signed changing invalidates the signature
+	for name, tamper := range map[string]func(*http.Request){
+		"method":    func(r *http.Request) { r.Method = http.MethodPost },
+		"path":      func(r *http.Request) { r.URL.Path = "/files/b" },
+		"offset":    func(r *http.Request) { r.Header.Set("Upload-Offset", "0") },
+		"length":    func(r *http.Request) { r.Header.Set("Upload-Length", "10") },
+		"timestamp": func(r *http.Request) { r.Header.Set(DefaultTimestampHeader, "1718035201") },
+		"signature": func(r *http.Request) { r.Header.Set(DefaultSignatureHeader, "AAAA") },
+		"encoding":  func(r *http.Request) { r.Header.Set(DefaultSignatureHeader, "!") },
+	} {
+		req = newRequest()
+		tamper(req)
+		assert.ErrorIs(t, signer.Verify(req, time.Minute), ErrBadSignature, name)
+	}
+
+	// unsigned headers may change
+	req = newRequest()
+	req.Header.Set("Content-Type", "text/plain")
+	assert.Nil(t, signer.Verify(req, time.Minute))
+
+	// stale
+	req = newRequest()
+	now = now.Add(2 * time.Minute)
+	assert.ErrorIs(t, signer.Verify(req, time.Minute), ErrBadSignature)
+
+	// a different key
+	req = newRequest()
+	assert.ErrorIs(t, (&HMACSigner{Key: []byte("other"), Now: signer.Now}).Verify(req, time.Minute), ErrBadSignature)
+
+	assert.ErrorIs(t, (&HMACSigner{}).Sign(req), ErrNilKey)
+}
+
+func TestHMACSignerHeaders(t *testing.T) {
+	signer := &HMACSigner{
+		Key:             []byte("secret"),
+		Headers:         []string{"Content-Type"},
+		SignatureHeader: "X-Sig",
+		TimestampHeader: "X-Sig-Time",
+	}
+
+	req, err := http.NewRequest(http.MethodPatch, "http://example.com/files/a", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	assert.Nil(t, signer.Sign(req))
+	assert.NotEmpty(t, req.Header.Get("X-Sig"))
+	assert.NotEmpty(t, req.Header.Get("X-Sig-Time"))
+	assert.Empty(t, req.Header.Get(DefaultKeyIDHeader))
+
+	// Upload-Offset isn't signed by this signer
+	req.Header.Set("Upload-Offset", "1")
+	assert.Nil(t, signer.Verify(req, time.Minute))
+
+	req.Header.Set("Content-Type", "text/plain")
+	assert.ErrorIs(t, signer.Verify(req, time.Minute), ErrBadSignature)
+}
@@ -1,5 +1,11 @@
 package tusc
 
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
 type Store interface {
 	Get(fingerprint string) (string, bool)
 	Set(fingerprint, url string)
@@ -7,31 +13,76 @@ type Store interface {
 	Close()
 }
 
+// ListableStore is a Store able to enumerate its fingerprints
+type ListableStore interface {
+	Store
+	Keys() []string
+}
+
+// recordKeyPrefixes of the records OfflineQueue, Watch and EncryptionOptions.Store may keep in Config.Store
+var recordKeyPrefixes = []string{queueEntryKeyPrefix, watchRecordKeyPrefix, encryptionRecordKeyPrefix}
+
+// IsUploadKey reports whether _key maps a fingerprint to an upload URL, rather than being one of the prefixed
+// records an OfflineQueue, Watch or EncryptionOptions.Store keeps in the same store
+func IsUploadKey(_key string) bool {
+	for _, prefix := range recordKeyPrefixes {
+		if strings.HasPrefix(_key, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// MemoryStore is safe for concurrent use, so may be shared by uploads running in parallel
 type MemoryStore struct {
-	m map[string]string
+	mu sync.RWMutex
+	m  map[string]string
 }
 
-func NewMemoryStore() Store {
+func NewMemoryStore() ListableStore {
 	return &MemoryStore{
-		make(map[string]string),
+		m: make(map[string]string),
 	}
 }
 
 func (s *MemoryStore) Get(fingerprint string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	url, ok := s.m[fingerprint]
 	return url, ok
 }
 
 func (s *MemoryStore) Set(fingerprint, url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.m[fingerprint] = url
 }
 
 func (s *MemoryStore) Delete(fingerprint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	delete(s.m, fingerprint)
 }
 
+func (s *MemoryStore) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedKeys(s.m)
+}
+
 func (s *MemoryStore) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	for k := range s.m {
 		delete(s.m, k)
 	}
 }
+
+func sortedKeys(_m map[string]string) []string {
+	keys := make([]string, 0, len(_m))
+	for k := range _m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,196 @@
+package tusc
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// uploadStream sends everything from the current offset in a single PATCH. If the request is interrupted the
+// offset is recovered from the server with a HEAD request and the remainder is streamed again, up to Config.Retries
+// times. Per request checksums are not sent in this mode as the body is never buffered.
+func (um *UploadMgr) uploadStream() error {
+	retries := 0
+	reauthenticated := false
+	for {
+		err := um.streamRemaining()
+		if err == nil || errors.Is(err, ErrUploadAborted) {
+			return nil
+		}
+
+		switch {
+		case errors.Is(err, ErrUnauthorized) && um.client.Config.Authenticator != nil && !reauthenticated:
+			// credentials were refreshed with the 401, resend once without using up a retry
+			reauthenticated = true
+		case retries >= um.client.Config.Retries || !isRetryable(err):
+			return err
+		default:
+			retries++
+		}
+
+		slog.Warn("streaming upload interrupted, resuming from server offset", "err", err, "attempt", retries)
+
+		offset, headErr := um.client.getUploadOffset(um.url)
+		if headErr != nil {
+			return errors.Join(err, headErr)
+		}
+
+		um.offset = offset
+		um.upload.setOffset(offset)
+	}
+}
+
+func (um *UploadMgr) streamRemaining() error {
+	size := um.upload.size - um.offset
+
+	var source io.Reader
+	if um.upload.readerAt != nil {
+		source = io.NewSectionReader(um.upload.readerAt, um.offset, size)
+	} else {
+		if _, err := um.upload.stream.Seek(um.offset, io.SeekStart); err != nil {
+			return err
+		}
+		source = io.LimitReader(um.upload.stream, size)
+	}
+	source, confirm := um.digest.tee(source, um.offset)
+
+	body := &streamBody{
+		reader:      source,
+		um:          um,
+		start:       um.offset,
+		size:        size,
+		checkpoint:  um.client.Config.StreamCheckpointBytes,
+		checkpoints: make(chan struct{}, 1),
+	}
+
+	contentLength := size
+	if um.client.Config.StreamUnknownLength {
+		contentLength = -1
+	}
+
+	// the PATCH runs on its own goroutine so checkpoints can be published from this one while the body is sent
+	result := make(chan streamResult, 1)
+	go func() {
+		offset, err := um.client.uploadChunk(um.ctx, um.url, um.throttle(um.ctx, body), "", contentLength, um.offset, -1)
+		result <- streamResult{offset: offset, err: err}
+	}()
+
+	var offset int64
+	var err error
+	for waiting := true; waiting; {
+		select {
+		case <-body.checkpoints:
+			um.checkpoint()
+		case res := <-result:
+			offset, err = res.offset, res.err
+			waiting = false
+		}
+	}
+
+	if err != nil {
+		confirm(um.offset)
+	} else {
+		confirm(offset)
+	}
+	if err != nil && um.aborted.Load() {
+		return ErrUploadAborted
+	}
+	if bodyErr := body.error(); bodyErr != nil {
+		// the transport wraps body errors, prefer the original so callers can match it
+		return bodyErr
+	}
+	if err != nil {
+		return err
+	}
+
+	um.offset = offset
+	um.publish(offset)
+
+	if um.offset < um.upload.size {
+		// server stopped reading early, pick up from where it got to
+		return ErrOffsetMismatch
+	}
+
+	return nil
+}
+
+type streamResult struct {
+	offset int64
+	err    error
+}
+
+// checkpoint publishes the offset the server has acknowledged so far, bytes read into the request body may still be
+// in flight. Errors are ignored, the offset is known for certain once the PATCH returns.
+func (um *UploadMgr) checkpoint() {
+	offset, err := um.client.getUploadOffset(um.url)
+	if err != nil || offset <= um.upload.Offset() || offset > um.upload.size {
+		return
+	}
+	um.publish(offset)
+}
+
+// streamBody signals a checkpoint every checkpoint bytes, stops the request when the upload is aborted and reports a
+// source shorter than the declared upload size. It is read by the transport goroutine, so it never touches the
+// upload itself.
+type streamBody struct {
+	reader      io.Reader
+	um          *UploadMgr
+	start       int64
+	size        int64
+	read        int64
+	checkpoint  int64
+	reported    int64
+	checkpoints chan struct{}
+
+	// body is read by the transport goroutine, which may outlive the request
+	mu  sync.Mutex
+	err error
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	if b.um.aborted.Load() {
+		return 0, b.fail(ErrUploadAborted)
+	}
+
+	n, err := b.reader.Read(p)
+	b.read += int64(n)
+
+	if errors.Is(err, io.EOF) && b.read < b.size {
+		return n, b.fail(shortStreamError(b.start+b.read, b.start+b.size))
+	}
+
+	if b.read-b.reported >= b.checkpoint {
+		b.reported = b.read
+		select {
+		case b.checkpoints <- struct{}{}:
+		default:
+			// a checkpoint is already pending
+		}
+	}
+
+	return n, err
+}
+
+func (b *streamBody) fail(err error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+	return err
+}
+
+func (b *streamBody) error() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// isRetryable reports whether resuming after err could succeed
+func isRetryable(err error) bool {
+	for _, permanent := range []error{ErrShortStream, ErrVersionMismatch, ErrLargeUpload, ErrUploadNotFound, ErrUnauthorized, ErrUploadAborted} {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+	return true
+}
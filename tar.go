@@ -0,0 +1,300 @@
+package tusc
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// TarOptions control the archive created by NewTarUpload
+type TarOptions struct {
+	// Dir [optional] selects the files to archive, see WalkDir
+	Dir *DirOptions
+	// Compression [optional] compresses the archive, defaults to CompressionNone
+	Compression Compression
+	// DeferLength [optional] create a deferred length upload rather than computing the length up front. The length
+	//   of an uncompressed archive is cheap to compute, a compressed archive needs a full compression pass.
+	DeferLength bool
+}
+
+// NewTarUpload creates an upload streaming the files under _root as a tar archive, without writing it to disk.
+// The archive is deterministic, so a resumed upload regenerates it and skips to the server offset, which means files
+// must not change until the upload completes (ErrSourceChanged). If _fingerprint is nil one is derived from the
+// listing and options.
+func NewTarUpload(_root string, _options *TarOptions, _fingerprint *string) (*Upload, error) {
+	if _options == nil {
+		_options = &TarOptions{}
+	}
+	if _options.Compression != CompressionNone {
+		if _, err := _options.Compression.newWriter(io.Discard); err != nil {
+			return nil, err
+		}
+	}
+
+	archive, err := newTarArchive(_root, _options)
+	if err != nil {
+		return nil, err
+	}
+
+	size := DeferredSize
+	if !_options.DeferLength {
+		if size, err = archive.size(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _fingerprint == nil {
+		fingerprint := archive.fingerprint()
+		_fingerprint = &fingerprint
+	}
+
+	name := filepath.Base(archive.root) + ".tar" + _options.Compression.extension()
+	metadata := Metadata{
+		MetadataFilename: name,
+		MetadataFiletype: archive.contentType(),
+	}
+
+	return NewUpload(&tarStream{archive: archive}, size, metadata, _fingerprint)
+}
+
+type tarFile struct {
+	DirFile
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+func (f *tarFile) header() *tar.Header {
+	// with Format unset ModTime is rounded to the second and access/change times dropped, nothing else varies
+	return &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     f.RelativePath,
+		Size:     f.size,
+		Mode:     int64(f.mode.Perm()),
+		ModTime:  f.modTime,
+	}
+}
+
+type tarArchive struct {
+	root        string
+	files       []tarFile
+	compression Compression
+}
+
+func newTarArchive(_root string, _options *TarOptions) (*tarArchive, error) {
+	root, err := filepath.Abs(_root)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := WalkDir(root, _options.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &tarArchive{
+		root:        root,
+		compression: _options.Compression,
+	}
+
+	for _, file := range files {
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		archive.files = append(archive.files, tarFile{
+			DirFile: file,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			mode:    info.Mode(),
+		})
+	}
+
+	return archive, nil
+}
+
+// size of the archive. Uncompressed it is the sum of each header plus content padded to the block size, compressed
+// the archive has to be generated.
+func (a *tarArchive) size() (int64, error) {
+	if a.compression != CompressionNone {
+		counter := &countingWriter{}
+		if err := a.writeTo(counter); err != nil {
+			return 0, err
+		}
+		return counter.n, nil
+	}
+
+	// Close writes two zero blocks
+	size := int64(2 * tarBlockSize)
+	for i := range a.files {
+		counter := &countingWriter{}
+		if err := tar.NewWriter(counter).WriteHeader(a.files[i].header()); err != nil {
+			return 0, err
+		}
+		size += counter.n + (a.files[i].size+tarBlockSize-1)/tarBlockSize*tarBlockSize
+	}
+
+	return size, nil
+}
+
+const tarBlockSize = 512
+
+func (a *tarArchive) fingerprint() string {
+	hasher := sha256.New()
+	hasher.Write([]byte(a.root))
+	hasher.Write([]byte{0})
+	hasher.Write([]byte(a.compression.String()))
+	for _, file := range a.files {
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(file.RelativePath))
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(strconv.FormatInt(file.size, 10)))
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(strconv.FormatInt(file.modTime.UnixNano(), 10)))
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+func (a *tarArchive) contentType() string {
+	switch a.compression {
+	case CompressionGzip:
+		return "application/gzip"
+	case CompressionZstd:
+		return "application/zstd"
+	default:
+		return "application/x-tar"
+	}
+}
+
+func (a *tarArchive) writeTo(_w io.Writer) error {
+	w := _w
+	var compressor io.WriteCloser
+	if a.compression != CompressionNone {
+		var err error
+		if compressor, err = a.compression.newWriter(_w); err != nil {
+			return err
+		}
+		w = compressor
+	}
+
+	tw := tar.NewWriter(w)
+	for i := range a.files {
+		if err := a.writeFile(tw, &a.files[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if compressor != nil {
+		return compressor.Close()
+	}
+	return nil
+}
+
+func (a *tarArchive) writeFile(_tw *tar.Writer, _file *tarFile) error {
+	file, err := os.Open(_file.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != _file.size || !info.ModTime().Equal(_file.modTime) {
+		return fmt.Errorf("%w: %s", ErrSourceChanged, _file.Path)
+	}
+
+	if err = _tw.WriteHeader(_file.header()); err != nil {
+		return err
+	}
+
+	if _, err = io.CopyN(_tw, file, _file.size); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrSourceChanged, _file.Path, err)
+	}
+
+	return nil
+}
+
+// tarStream generates the archive on demand. Seeking forward discards output, seeking backwards regenerates the
+// archive from the start.
+type tarStream struct {
+	archive  *tarArchive
+	pipe     *io.PipeReader
+	position int64
+}
+
+func (s *tarStream) Read(p []byte) (int, error) {
+	if s.pipe == nil {
+		s.start()
+	}
+
+	n, err := s.pipe.Read(p)
+	s.position += int64(n)
+	return n, err
+}
+
+func (s *tarStream) Seek(_offset int64, _whence int) (int64, error) {
+	switch _whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		_offset += s.position
+	default:
+		return s.position, ErrSeekUnsupported
+	}
+
+	if _offset < 0 {
+		return s.position, ErrSeekUnsupported
+	}
+
+	if _offset < s.position {
+		s.Close()
+		s.position = 0
+	}
+
+	if _offset > s.position {
+		if _, err := io.CopyN(io.Discard, s, _offset-s.position); err != nil {
+			return s.position, err
+		}
+	}
+
+	return s.position, nil
+}
+
+// Close stops the archive generator
+func (s *tarStream) Close() error {
+	if s.pipe != nil {
+		s.pipe.Close()
+		s.pipe = nil
+	}
+	return nil
+}
+
+func (s *tarStream) start() {
+	reader, writer := io.Pipe()
+	s.pipe = reader
+
+	go func() {
+		writer.CloseWithError(s.archive.writeTo(writer))
+	}()
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
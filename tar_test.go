@@ -0,0 +1,102 @@
+package tusc
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func readTestTar(t *testing.T, _archive io.Reader) map[string]string {
+	files := map[string]string{}
+	tr := tar.NewReader(_archive)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		assert.Nil(t, err)
+		content, err := io.ReadAll(tr)
+		assert.Nil(t, err)
+		files[header.Name] = string(content)
+	}
+}
+
+func TestTarUploadSize(t *testing.T) {
+	root := t.TempDir()
+	writeTestTree(t, root, "a.txt", "sub/b.txt", "sub/"+string(bytes.Repeat([]byte("long"), 50))+".txt")
+
+	for _, compression := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		upload, err := NewTarUpload(root, &TarOptions{Compression: compression}, nil)
+		assert.Nil(t, err)
+		assert.NotEmpty(t, upload.Fingerprint)
+
+		archive, err := io.ReadAll(upload.stream)
+		assert.Nil(t, err)
+		assert.EqualValues(t, len(archive), upload.Size(), compression.String())
+	}
+}
+
+func TestTarUploadContent(t *testing.T) {
+	root := t.TempDir()
+	writeTestTree(t, root, "a.txt", "sub/b.txt")
+	expected := map[string]string{"a.txt": "a.txt", "sub/b.txt": "sub/b.txt"}
+
+	upload, err := NewTarUpload(root, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, readTestTar(t, upload.stream))
+
+	upload, err = NewTarUpload(root, &TarOptions{Compression: CompressionGzip}, nil)
+	assert.Nil(t, err)
+	gz, err := gzip.NewReader(upload.stream)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, readTestTar(t, gz))
+
+	upload, err = NewTarUpload(root, &TarOptions{Compression: CompressionZstd, DeferLength: true}, nil)
+	assert.Nil(t, err)
+	assert.True(t, upload.Deferred())
+	zr, err := zstd.NewReader(upload.stream)
+	assert.Nil(t, err)
+	defer zr.Close()
+	assert.Equal(t, expected, readTestTar(t, zr))
+}
+
+func TestTarUploadSeek(t *testing.T) {
+	root := t.TempDir()
+	writeTestTree(t, root, "a.txt", "sub/b.txt")
+
+	upload, err := NewTarUpload(root, &TarOptions{Compression: CompressionGzip}, nil)
+	assert.Nil(t, err)
+	archive, err := io.ReadAll(upload.stream)
+	assert.Nil(t, err)
+
+	// backwards regenerates, forwards discards
+	for _, offset := range []int64{60, 10, 100} {
+		position, err := upload.stream.Seek(offset, io.SeekStart)
+		assert.Nil(t, err)
+		assert.Equal(t, offset, position)
+
+		rest, err := io.ReadAll(upload.stream)
+		assert.Nil(t, err)
+		assert.Equal(t, archive[offset:], rest)
+	}
+}
+
+func TestTarUploadSourceChanged(t *testing.T) {
+	root := t.TempDir()
+	writeTestTree(t, root, "a.txt")
+
+	upload, err := NewTarUpload(root, nil, nil)
+	assert.Nil(t, err)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("changed"), 0o600))
+
+	_, err = io.ReadAll(upload.stream)
+	assert.ErrorIs(t, err, ErrSourceChanged)
+}
@@ -2,24 +2,34 @@ package tusc
 
 import (
 	"bytes"
-	"encoding/base64"
-	"fmt"
+	"errors"
 	"io"
 	"os"
-	"strings"
 )
 
 type Metadata map[string]string
 
+// DeferredSize declares an upload whose length is unknown until the stream ends, requires the server to support
+// the creation-defer-length extension
+const DeferredSize int64 = -1
+
 type Upload struct {
 	stream io.ReadSeeker
 	size   int64
 	offset int64
+	// file set when created from an *os.File, used by FileInfoFingerprinter
+	file *os.File
+	// readerAt set when the source supports random access, allowing chunks to be read without seeking stream
+	readerAt io.ReaderAt
+	// release [optional] drops state recorded only to resume the upload, called once it is complete or terminated
+	release func()
 
 	Fingerprint string
 	Metadata    Metadata
 }
 
+// NewUpload creates an upload from a stream. If _fingerprint is nil the fingerprint is left unset and will be
+// generated by Config.Fingerprinter when the upload is created or resumed.
 func NewUpload(_stream io.ReadSeeker, _size int64, _metadata Metadata, _fingerprint *string) (*Upload, error) {
 	_, err := _stream.Seek(0, io.SeekStart)
 	if err != nil {
@@ -30,34 +40,69 @@ func NewUpload(_stream io.ReadSeeker, _size int64, _metadata Metadata, _fingerpr
 		_metadata = make(Metadata)
 	}
 
-	return &Upload{
+	var fingerprint string
+	if _fingerprint != nil {
+		fingerprint = *_fingerprint
+	}
+
+	upload := &Upload{
 		stream:      _stream,
 		size:        _size,
-		Fingerprint: *_fingerprint,
+		Fingerprint: fingerprint,
 		Metadata:    _metadata,
-	}, nil
+	}
+
+	if readerAt, ok := _stream.(io.ReaderAt); ok {
+		upload.readerAt = readerAt
+	}
+
+	return upload, nil
 }
 
-func NewUploadFromFile(_file *os.File, _fingerprint *string) (*Upload, error) {
-	if _fingerprint == nil {
-		return nil, ErrFingerprintUnset
+// NewUploadFromReaderAt creates an upload from a random access source e.g. a file or mmap. Chunks are read with
+// io.SectionReader so no chunk sized buffer is allocated and chunks may be read concurrently.
+func NewUploadFromReaderAt(_readerAt io.ReaderAt, _size int64, _metadata Metadata, _fingerprint *string) (*Upload, error) {
+	if _readerAt == nil {
+		return nil, ErrNilUpload
 	}
+
+	return NewUpload(io.NewSectionReader(_readerAt, 0, _size), _size, _metadata, _fingerprint)
+}
+
+func NewUploadFromFile(_file *os.File, _fingerprint *string) (*Upload, error) {
 	fileInfo, err := _file.Stat()
 	if err != nil {
 		return nil, err
 	}
 
 	metadata := Metadata{
-		"filename": fileInfo.Name(),
+		MetadataFilename: fileInfo.Name(),
 	}
 
-	return NewUpload(_file, fileInfo.Size(), metadata, _fingerprint)
+	upload, err := NewUpload(_file, fileInfo.Size(), metadata, _fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	upload.file = _file
+
+	return upload, nil
 }
 
-func NewUploadFromBytes(_bytes []byte, _fingerprint *string) (*Upload, error) {
-	if _fingerprint == nil {
-		return nil, ErrFingerprintUnset
+// NewUploadFromFileWithFingerprinter creates an upload from a file, generating the fingerprint with _fingerprinter.
+func NewUploadFromFileWithFingerprinter(_file *os.File, _fingerprinter Fingerprinter) (*Upload, error) {
+	upload, err := NewUploadFromFile(_file, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = upload.GenerateFingerprint(_fingerprinter); err != nil {
+		return nil, err
 	}
+
+	return upload, nil
+}
+
+func NewUploadFromBytes(_bytes []byte, _fingerprint *string) (*Upload, error) {
 	if _bytes == nil {
 		return nil, ErrNilUpload
 	}
@@ -69,6 +114,38 @@ func NewUploadFromBytes(_bytes []byte, _fingerprint *string) (*Upload, error) {
 	return NewUpload(buffer, buffer.Size(), nil, _fingerprint)
 }
 
+// NewUploadFromBytesWithFingerprinter creates an upload from bytes, generating the fingerprint with _fingerprinter.
+func NewUploadFromBytesWithFingerprinter(_bytes []byte, _fingerprinter Fingerprinter) (*Upload, error) {
+	upload, err := NewUploadFromBytes(_bytes, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = upload.GenerateFingerprint(_fingerprinter); err != nil {
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// GenerateFingerprint replaces the upload fingerprint with one generated by _fingerprinter.
+func (u *Upload) GenerateFingerprint(_fingerprinter Fingerprinter) error {
+	if _fingerprinter == nil {
+		return ErrFingerprintUnset
+	}
+
+	fingerprint, err := _fingerprinter.Fingerprint(u)
+	if err != nil {
+		return err
+	}
+	if fingerprint == "" {
+		return ErrFingerprintUnset
+	}
+
+	u.Fingerprint = fingerprint
+	return nil
+}
+
 func (u *Upload) setOffset(offset int64) {
 	u.offset = offset
 }
@@ -81,24 +158,42 @@ func (u *Upload) Size() int64 {
 	return u.size
 }
 
-// Progress of the current upload as percentage
+// Deferred reports whether the upload length is still unknown
+func (u *Upload) Deferred() bool {
+	return u.size < 0
+}
+
+// Progress of the current upload as percentage, always 0 while the length is deferred
 func (u *Upload) Progress() int64 {
 	if u.size == 0 {
 		return 100
+	} else if u.size < 0 {
+		return 0
 	}
 	return (u.offset * 100) / u.size
 }
 
+// EncodedMetadata the Upload-Metadata header in sorted key order, without validating keys, see Metadata.Encode
 func (u *Upload) EncodedMetadata() string {
-	var encoded []string
+	return u.Metadata.encode()
+}
 
-	for k, v := range u.Metadata {
-		encoded = append(encoded, fmt.Sprintf("%s %s", k, b64encode(v)))
+// readFullAt fills _p from _offset of an upload source of _size bytes, using _readerAt if set and seeking _stream
+// otherwise. Reaching the end early is ErrShortStream.
+func readFullAt(_stream io.ReadSeeker, _readerAt io.ReaderAt, _p []byte, _offset int64, _size int64) error {
+	var n int
+	var err error
+	if _readerAt != nil {
+		n, err = _readerAt.ReadAt(_p, _offset)
+		if errors.Is(err, io.EOF) && n == len(_p) {
+			err = nil
+		}
+	} else if _, err = _stream.Seek(_offset, io.SeekStart); err == nil {
+		n, err = io.ReadFull(_stream, _p)
 	}
 
-	return strings.Join(encoded, ",")
-}
-
-func b64encode(s string) string {
-	return base64.StdEncoding.EncodeToString([]byte(s))
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return shortStreamError(_offset+int64(n), _size)
+	}
+	return err
 }
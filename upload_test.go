@@ -16,6 +16,9 @@ func TestEncodedMetadata(t *testing.T) {
 	u.Metadata["filename"] = "foobar.txt"
 	enc := u.EncodedMetadata()
 	assert.Equal(t, "filename Zm9vYmFyLnR4dA==", enc)
+
+	u.Metadata["empty"] = ""
+	assert.Equal(t, "empty,filename Zm9vYmFyLnR4dA==", u.EncodedMetadata())
 }
 
 func TestNewUploadFromFile(t *testing.T) {
@@ -0,0 +1,451 @@
+package tusc
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWatchStableFor    = 5 * time.Second
+	defaultWatchPollInterval = 2 * time.Second
+
+	// watchRecordKeyPrefix keeps records apart from the upload URLs Config.Store maps fingerprints to
+	watchRecordKeyPrefix = "watch:"
+)
+
+// WatchAction what Client.Watch does with a file once uploaded
+type WatchAction int
+
+const (
+	// WatchMark leaves the file in place, recording it as uploaded in WatchOptions.State
+	WatchMark WatchAction = iota
+	// WatchMove moves the file under WatchOptions.MoveTo, keeping its path relative to the watched directory
+	WatchMove
+	// WatchDelete removes the file
+	WatchDelete
+)
+
+// WatchOptions control Client.Watch
+type WatchOptions struct {
+	// Dir [optional] selects the files to upload and the Fingerprinter and Workers used, see WalkDir
+	Dir *DirOptions
+	// State records uploaded files by fingerprint, a persistent store (e.g. FileStore) means a restart never uploads
+	//   a file twice. Config.Store should also be persistent so interrupted uploads resume, and may be used as State
+	//   as well since records are keyed "watch:" + fingerprint.
+	State Store
+	// Action applied to uploaded files, defaults to WatchMark
+	Action WatchAction
+	// MoveTo directory files are moved to with WatchMove, on the same filesystem as the watched directory. It is
+	//   never watched, even if inside the watched directory.
+	MoveTo string
+	// StableFor [optional] how long a file's size and mtime must be unchanged before it is uploaded, defaults to 5s
+	StableFor time.Duration
+	// PollInterval [optional] how often the directory is rescanned, defaults to 2s. With change notifications
+	//   (inotify on Linux) it is only used while files are waiting to stabilise.
+	PollInterval time.Duration
+	// Poll [optional] always poll rather than use change notifications, e.g. for network filesystems
+	Poll bool
+	// OnEvent [optional] called once per file handled, from the uploading goroutine
+	OnEvent func(WatchEvent)
+}
+
+// WatchEvent the outcome of handling a single file
+type WatchEvent struct {
+	DirFile
+	Fingerprint string
+	URL         string
+	// Skipped the file had already been uploaded according to WatchOptions.State
+	Skipped bool
+	// Err non-nil if the upload or action failed, the file is retried once stable again
+	Err error
+}
+
+// watchRecord the State value for an uploaded file
+type watchRecord struct {
+	Path     string    `json:"path"`
+	URL      string    `json:"url"`
+	Uploaded time.Time `json:"uploaded"`
+	// Done the action has been applied too
+	Done bool `json:"done"`
+}
+
+// Watch uploads files appearing under _root until _ctx is cancelled. A file is uploaded with CreateOrResumeUpload
+// once it has stopped changing, then WatchOptions.Action is applied and the file recorded in WatchOptions.State.
+// Files already present are handled too. Watch returns once in progress uploads have been aborted.
+func (c *Client) Watch(_ctx context.Context, _root string, _options *WatchOptions) error {
+	w, err := newDirWatcher(c, _root, _options)
+	if err != nil {
+		return err
+	}
+	return w.run(_ctx)
+}
+
+// watchedFile the last observed state of a file
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+	// since when size and modTime have been unchanged
+	since time.Time
+	// busy being uploaded
+	busy bool
+	// handled uploaded or skipped, ignored until it changes
+	handled bool
+}
+
+type dirWatcher struct {
+	client        *Client
+	root          string
+	options       *WatchOptions
+	dirOptions    *DirOptions
+	fingerprinter Fingerprinter
+	moveTo        string
+
+	mu      sync.Mutex
+	files   map[string]*watchedFile
+	workers chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newDirWatcher(_client *Client, _root string, _options *WatchOptions) (*dirWatcher, error) {
+	if _options == nil || _options.State == nil {
+		return nil, ErrNilStore
+	}
+	if _options.Action < WatchMark || _options.Action > WatchDelete || (_options.Action == WatchMove && _options.MoveTo == "") {
+		return nil, ErrBadWatchAction
+	}
+
+	root, err := filepath.Abs(_root)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(root); err != nil {
+		return nil, err
+	}
+
+	w := &dirWatcher{
+		client:     _client,
+		root:       root,
+		options:    _options,
+		dirOptions: _options.Dir,
+		files:      make(map[string]*watchedFile),
+	}
+	if w.dirOptions == nil {
+		w.dirOptions = &DirOptions{}
+	}
+	if _options.MoveTo != "" {
+		if w.moveTo, err = filepath.Abs(_options.MoveTo); err != nil {
+			return nil, err
+		}
+	}
+
+	w.fingerprinter = w.dirOptions.Fingerprinter
+	if w.fingerprinter == nil {
+		w.fingerprinter = _client.Config.Fingerprinter
+	}
+	if w.fingerprinter == nil {
+		w.fingerprinter = &FileInfoFingerprinter{}
+	}
+	w.workers = make(chan struct{}, max(w.dirOptions.Workers, 1))
+
+	return w, nil
+}
+
+func (w *dirWatcher) run(_ctx context.Context) error {
+	interval := w.options.PollInterval
+	if interval <= 0 {
+		interval = defaultWatchPollInterval
+	}
+
+	var notifier watchNotifier
+	if !w.options.Poll {
+		var err error
+		if notifier, err = newWatchNotifier(); err != nil {
+			slog.Info("change notifications unavailable, polling", "err", err)
+			notifier = nil
+		} else {
+			defer notifier.close()
+		}
+	}
+
+	var changes <-chan struct{}
+	if notifier != nil {
+		changes = notifier.changes()
+	}
+
+	defer w.wg.Wait()
+
+	for {
+		dirs, waiting := w.scan(_ctx)
+		if notifier != nil {
+			for _, dir := range dirs {
+				if err := notifier.add(dir); err != nil {
+					slog.Warn("unable to watch directory", "dir", dir, "err", err)
+				}
+			}
+		}
+
+		var tick <-chan time.Time
+		var timer *time.Timer
+		if notifier == nil || waiting {
+			timer = time.NewTimer(interval)
+			tick = timer.C
+		}
+
+		select {
+		case <-_ctx.Done():
+		case <-changes:
+		case <-tick:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if _ctx.Err() != nil {
+			return _ctx.Err()
+		}
+	}
+}
+
+// scan walks the directory, starting uploads of files which have become stable. It returns the directories seen,
+// for change notifications, and whether any file is still waiting to stabilise.
+func (w *dirWatcher) scan(_ctx context.Context) ([]string, bool) {
+	files, err := WalkDir(w.root, w.dirOptions)
+	if err != nil {
+		slog.Warn("unable to scan watched directory", "dir", w.root, "err", err)
+		return nil, true
+	}
+
+	stableFor := w.options.StableFor
+	if stableFor <= 0 {
+		stableFor = defaultWatchStableFor
+	}
+
+	now := time.Now()
+	dirs := map[string]bool{w.root: true}
+	seen := make(map[string]bool, len(files))
+	waiting := false
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, file := range files {
+		if w.moveTo != "" && isWithin(w.moveTo, file.Path) {
+			continue
+		}
+		for dir := filepath.Dir(file.Path); isWithin(w.root, dir) && !dirs[dir]; dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+		seen[file.Path] = true
+
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			continue
+		}
+
+		state, ok := w.files[file.Path]
+		if !ok || state.size != info.Size() || !state.modTime.Equal(info.ModTime()) {
+			if ok && state.busy {
+				// changed mid upload, the upload fails or records the old content, either way look again after
+				waiting = true
+				continue
+			}
+			w.files[file.Path] = &watchedFile{size: info.Size(), modTime: info.ModTime(), since: now}
+			waiting = true
+			continue
+		}
+
+		if state.busy || state.handled {
+			continue
+		}
+		if now.Sub(state.since) < stableFor {
+			waiting = true
+			continue
+		}
+
+		state.busy = true
+		w.start(_ctx, file)
+	}
+
+	for path, state := range w.files {
+		if !seen[path] && !state.busy {
+			delete(w.files, path)
+		}
+	}
+
+	list := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		list = append(list, dir)
+	}
+	return list, waiting
+}
+
+// start uploads _file on a worker, callers must hold mu
+func (w *dirWatcher) start(_ctx context.Context, _file DirFile) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		select {
+		case w.workers <- struct{}{}:
+		case <-_ctx.Done():
+			w.finish(_file, false)
+			return
+		}
+		event := w.handle(_ctx, _file)
+		<-w.workers
+
+		if _ctx.Err() != nil && event.Err != nil {
+			// interrupted by shutdown, not a failure
+			w.finish(_file, false)
+			return
+		}
+
+		w.finish(_file, event.Err == nil)
+		if w.options.OnEvent != nil {
+			w.options.OnEvent(event)
+		}
+	}()
+}
+
+func (w *dirWatcher) finish(_file DirFile, _handled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if state, ok := w.files[_file.Path]; ok {
+		state.busy = false
+		state.handled = _handled
+		// a failed file waits to be stable again before it is retried
+		state.since = time.Now()
+	}
+}
+
+// handle uploads a stable file unless State shows it already was, then applies the action
+func (w *dirWatcher) handle(_ctx context.Context, _file DirFile) WatchEvent {
+	event := WatchEvent{DirFile: _file}
+
+	upload, err := openDirFile(_file, w.fingerprinter)
+	if err != nil {
+		event.Err = err
+		return event
+	}
+	closeFile := func() {
+		if closer, ok := upload.stream.(interface{ Close() error }); ok {
+			closer.Close()
+		}
+	}
+	defer closeFile()
+	event.Fingerprint = upload.Fingerprint
+
+	record, ok := w.record(upload.Fingerprint)
+	switch {
+	case ok && record.Done:
+		event.URL = record.URL
+		event.Skipped = true
+		return event
+	case ok:
+		// uploaded but interrupted before the action was applied
+		event.URL = record.URL
+	default:
+		if event.URL, err = w.upload(_ctx, upload); err != nil {
+			event.Err = err
+			return event
+		}
+		record = watchRecord{Path: _file.Path, URL: event.URL, Uploaded: time.Now().UTC()}
+		if err = w.setRecord(upload.Fingerprint, record); err != nil {
+			event.Err = err
+			return event
+		}
+	}
+
+	// release the file before moving or deleting it
+	closeFile()
+	if err = w.apply(_file); err != nil {
+		event.Err = err
+		return event
+	}
+
+	record.Done = true
+	event.Err = w.setRecord(upload.Fingerprint, record)
+
+	return event
+}
+
+func (w *dirWatcher) upload(_ctx context.Context, _upload *Upload) (string, error) {
+	uploadMgr, err := w.client.CreateOrResumeUpload(_upload)
+	if err != nil {
+		return "", err
+	}
+
+	stop := context.AfterFunc(_ctx, uploadMgr.Abort)
+	defer stop()
+
+	if err = uploadMgr.Upload(); err != nil {
+		return "", err
+	}
+	if !uploadMgr.complete() {
+		return "", ErrUploadAborted
+	}
+
+	return uploadMgr.URL(), nil
+}
+
+func (w *dirWatcher) apply(_file DirFile) error {
+	switch w.options.Action {
+	case WatchMove:
+		target := filepath.Join(w.moveTo, filepath.FromSlash(_file.RelativePath))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		return os.Rename(_file.Path, target)
+	case WatchDelete:
+		return os.Remove(_file.Path)
+	default:
+		return nil
+	}
+}
+
+func (w *dirWatcher) record(_fingerprint string) (watchRecord, bool) {
+	var record watchRecord
+
+	value, ok := w.options.State.Get(watchRecordKey(_fingerprint))
+	if !ok {
+		return record, false
+	}
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		slog.Warn("ignoring unreadable watch record", "fingerprint", _fingerprint, "err", err)
+		return record, false
+	}
+
+	return record, true
+}
+
+func (w *dirWatcher) setRecord(_fingerprint string, _record watchRecord) error {
+	value, err := json.Marshal(_record)
+	if err != nil {
+		return err
+	}
+	w.options.State.Set(watchRecordKey(_fingerprint), string(value))
+	return nil
+}
+
+func watchRecordKey(_fingerprint string) string {
+	return watchRecordKeyPrefix + _fingerprint
+}
+
+// isWithin reports whether _path is _dir or below it
+func isWithin(_dir string, _path string) bool {
+	rel, err := filepath.Rel(_dir, _path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// watchNotifier signals that something in a watched directory may have changed
+type watchNotifier interface {
+	add(_dir string) error
+	changes() <-chan struct{}
+	close() error
+}
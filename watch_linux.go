@@ -0,0 +1,111 @@
+//go:build linux
+
+package tusc
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_MODIFY |
+	syscall.IN_ATTRIB | syscall.IN_DELETE_SELF
+
+// inotifyNotifier wakes the watcher on any inotify event. Events are only decoded to forget removed watches, the
+// directory is rescanned anyway.
+type inotifyNotifier struct {
+	file   *os.File
+	signal chan struct{}
+
+	mu      sync.Mutex
+	watched map[string]int32
+	dirs    map[int32]string
+}
+
+func newWatchNotifier() (watchNotifier, error) {
+	// non-blocking so the runtime poller can interrupt reads on close
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	n := &inotifyNotifier{
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		signal:  make(chan struct{}, 1),
+		watched: make(map[string]int32),
+		dirs:    make(map[int32]string),
+	}
+	go n.read()
+
+	return n, nil
+}
+
+func (n *inotifyNotifier) add(_dir string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.watched[_dir]; ok {
+		return nil
+	}
+
+	conn, err := n.file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var wd int
+	var watchErr error
+	err = conn.Control(func(fd uintptr) {
+		wd, watchErr = syscall.InotifyAddWatch(int(fd), _dir, inotifyMask)
+	})
+	if err == nil {
+		err = watchErr
+	}
+	if err != nil {
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	n.watched[_dir] = int32(wd)
+	n.dirs[int32(wd)] = _dir
+	return nil
+}
+
+func (n *inotifyNotifier) changes() <-chan struct{} {
+	return n.signal
+}
+
+func (n *inotifyNotifier) close() error {
+	return n.file.Close()
+}
+
+func (n *inotifyNotifier) read() {
+	buf := make([]byte, 64*1024)
+	for {
+		read, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+		n.forgetRemoved(buf[:read])
+
+		select {
+		case n.signal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// forgetRemoved drops watches the kernel removed, e.g. when the directory was deleted, so they are added again if
+// the directory is recreated
+func (n *inotifyNotifier) forgetRemoved(_events []byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for len(_events) >= syscall.SizeofInotifyEvent {
+		event := (*syscall.InotifyEvent)(unsafe.Pointer(&_events[0]))
+		if event.Mask&syscall.IN_IGNORED != 0 {
+			delete(n.watched, n.dirs[event.Wd])
+			delete(n.dirs, event.Wd)
+		}
+		_events = _events[min(syscall.SizeofInotifyEvent+int(event.Len), len(_events)):]
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package tusc
+
+import "errors"
+
+func newWatchNotifier() (watchNotifier, error) {
+	return nil, errors.New("change notifications unsupported on this platform")
+}
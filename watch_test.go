@@ -0,0 +1,102 @@
+package tusc
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsWithin(t *testing.T) {
+	root := filepath.FromSlash("/data/in")
+	assert.True(t, isWithin(root, root))
+	assert.True(t, isWithin(root, filepath.Join(root, "a", "b")))
+	assert.True(t, isWithin(root, filepath.Join(root, "..a")))
+	assert.False(t, isWithin(root, filepath.FromSlash("/data/inbox")))
+	assert.False(t, isWithin(root, filepath.FromSlash("/data")))
+}
+
+func TestNewDirWatcherValidation(t *testing.T) {
+	client := &Client{Config: DefaultConfig()}
+	root := t.TempDir()
+
+	_, err := newDirWatcher(client, root, nil)
+	assert.ErrorIs(t, err, ErrNilStore)
+
+	_, err = newDirWatcher(client, root, &WatchOptions{State: NewMemoryStore(), Action: WatchMove})
+	assert.ErrorIs(t, err, ErrBadWatchAction)
+
+	_, err = newDirWatcher(client, root, &WatchOptions{State: NewMemoryStore(), Action: WatchAction(7)})
+	assert.ErrorIs(t, err, ErrBadWatchAction)
+
+	_, err = newDirWatcher(client, filepath.Join(root, "missing"), &WatchOptions{State: NewMemoryStore()})
+	assert.NotNil(t, err)
+}
+
+func TestWatchSkipsUploadedAfterRestart(t *testing.T) {
+	server := newSwitchableServer(t)
+	server.online.Store(true)
+	root := t.TempDir()
+	writeTestTree(t, root, "a.txt")
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	watch := func() WatchEvent {
+		// the same store holds upload URLs and watch records
+		store, err := NewFileStore(statePath)
+		assert.Nil(t, err)
+		defer store.Close()
+
+		cfg := DefaultConfig()
+		cfg.Store = store
+		client, err := NewClient(server.url, cfg)
+		assert.Nil(t, err)
+
+		events := make(chan WatchEvent, 1)
+		options := &WatchOptions{
+			State:        store,
+			StableFor:    10 * time.Millisecond,
+			PollInterval: 10 * time.Millisecond,
+			OnEvent:      func(e WatchEvent) { events <- e },
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan error)
+		go func() {
+			stopped <- client.Watch(ctx, root, options)
+		}()
+		defer func() {
+			cancel()
+			<-stopped
+		}()
+
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("file not handled")
+			return WatchEvent{}
+		}
+	}
+
+	first := watch()
+	assert.Nil(t, first.Err)
+	assert.False(t, first.Skipped)
+	assert.NotEmpty(t, first.URL)
+
+	// restarted with the persisted state
+	second := watch()
+	assert.Nil(t, second.Err)
+	assert.True(t, second.Skipped)
+	assert.Equal(t, first.Fingerprint, second.Fingerprint)
+
+	store, err := NewFileStore(statePath)
+	assert.Nil(t, err)
+	defer store.Close()
+	url, ok := store.Get(first.Fingerprint)
+	assert.True(t, ok)
+	assert.Equal(t, first.URL, url)
+	_, ok = store.Get(watchRecordKey(first.Fingerprint))
+	assert.True(t, ok)
+}